@@ -0,0 +1,155 @@
+package dou
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is format of access log.
+type AccessLogFormat int
+
+// Formats of access log.
+const (
+	// CommonLog is Apache common log format.
+	//     %h %l %u %t "%r" %>s %b
+	CommonLog AccessLogFormat = iota
+	// CombinedLog is Apache combined log format.
+	//     %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+	CombinedLog
+	// JSONLog is a JSON object per line.
+	JSONLog
+)
+
+// AccessLogger writes access log of API.
+// Set this to API.AccessLogger.
+// The log is emitted after API.AfterDispatch with status code and size recorded by SafeWriter.
+// So it is emitted even if panic occur and API.OnPanic writes the response.
+type AccessLogger struct {
+	Writer io.Writer
+	Format AccessLogFormat
+
+	mu sync.Mutex
+}
+
+// NewAccessLogger new AccessLogger that writes log in format to w.
+func NewAccessLogger(w io.Writer, format AccessLogFormat) *AccessLogger {
+	return &AccessLogger{Writer: w, Format: format}
+}
+
+// accessLogEntry is a line of JSONLog.
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	User       string  `json:"user,omitempty"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	Size       int64   `json:"size"`
+	Duration   float64 `json:"duration"` // seconds
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	RequestID  string  `json:"request_id,omitempty"`
+	Panic      bool    `json:"panic,omitempty"`
+}
+
+// Log writes access log of r that is responded by sw.
+func (al *AccessLogger) Log(sw *SafeWriter, r *http.Request) {
+	start := time.Now()
+	rc := FromRequest(r)
+
+	if rc != nil {
+		start = rc.Start
+	}
+
+	status := sw.Status
+
+	if status == 0 {
+		// net/http responds 200 if nothing is written.
+		status = http.StatusOK
+	}
+
+	host := r.RemoteAddr
+
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+
+	user := ""
+
+	if u, _, ok := r.BasicAuth(); ok {
+		user = u
+	}
+
+	var line []byte
+
+	switch al.Format {
+	case JSONLog:
+		entry := &accessLogEntry{
+			Time:       start.Format(time.RFC3339Nano),
+			RemoteAddr: host,
+			User:       user,
+			Method:     r.Method,
+			URI:        r.URL.RequestURI(),
+			Proto:      r.Proto,
+			Status:     status,
+			Size:       sw.Written,
+			Duration:   time.Since(start).Seconds(),
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		}
+
+		if rc != nil {
+			entry.RequestID = rc.RequestID
+			entry.Panic = rc.Panic != nil
+		}
+
+		b, err := json.Marshal(entry)
+
+		if err != nil {
+			return
+		}
+
+		line = append(b, '\n')
+	default:
+		size := "-"
+
+		if sw.Written > 0 {
+			size = strconv.FormatInt(sw.Written, 10)
+		}
+
+		line = []byte(fmt.Sprintf("%s - %s [%s] %s %d %s",
+			host,
+			orHyphen(user),
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			strconv.Quote(r.Method+" "+r.URL.RequestURI()+" "+r.Proto),
+			status,
+			size,
+		))
+
+		if al.Format == CombinedLog {
+			line = append(line, fmt.Sprintf(" %s %s", strconv.Quote(orHyphen(r.Referer())), strconv.Quote(orHyphen(r.UserAgent())))...)
+		}
+
+		line = append(line, '\n')
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.Writer.Write(line)
+}
+
+func orHyphen(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,110 @@
+package dou
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func newTestAccessLogAPI(format AccessLogFormat) (*API, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+
+	a := newTestAPI()
+	a.LogStackTrace = false
+	a.AccessLogger = NewAccessLogger(buf, format)
+
+	a.OnPanic = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal server error"))
+	}
+
+	return a, buf
+}
+
+func newTestAccessLogRequest() *http.Request {
+	request, _ := http.NewRequest("GET", "/users?page=2", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.Header.Set("Referer", "http://example.com/")
+	request.Header.Set("User-Agent", "dou-test")
+	request.SetBasicAuth("toqoz", "secret")
+	return request
+}
+
+func TestAccessLogCommonAndCombined(t *testing.T) {
+	tests := []struct {
+		format   AccessLogFormat
+		expected *regexp.Regexp
+	}{
+		{
+			CommonLog,
+			regexp.MustCompile(`^192\.0\.2\.1 - toqoz \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /users\?page=2 HTTP/1\.1" 201 5\n$`),
+		},
+		{
+			CombinedLog,
+			regexp.MustCompile(`^192\.0\.2\.1 - toqoz \[.+\] "GET /users\?page=2 HTTP/1\.1" 201 5 "http://example\.com/" "dou-test"\n$`),
+		},
+	}
+
+	for _, test := range tests {
+		a, buf := newTestAccessLogAPI(test.format)
+		a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("hello"))
+		})
+
+		a.ServeHTTP(httptest.NewRecorder(), newTestAccessLogRequest())
+
+		if !test.expected.Match(buf.Bytes()) {
+			t.Errorf("unexpected access log\nexpected: %v\ngot: %s\n", test.expected, buf.Bytes())
+		}
+	}
+}
+
+func TestAccessLogIsEmittedAfterOnPanic(t *testing.T) {
+	a, buf := newTestAccessLogAPI(JSONLog)
+	a.Handler = http.HandlerFunc(panicInHandler)
+	a.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		FromRequest(r).RequestID = "req-1"
+		return w, r
+	}
+
+	a.ServeHTTP(httptest.NewRecorder(), newTestAccessLogRequest())
+
+	entry := map[string]interface{}{}
+
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("JSONLog should write a JSON object, but got %s", buf.Bytes())
+	}
+
+	expected := map[string]interface{}{
+		"remote_addr": "192.0.2.1",
+		"user":        "toqoz",
+		"method":      "GET",
+		"uri":         "/users?page=2",
+		"status":      float64(http.StatusInternalServerError),
+		"size":        float64(len("internal server error")),
+		"user_agent":  "dou-test",
+		"request_id":  "req-1",
+		"panic":       true,
+	}
+
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("access log should have %s written by OnPanic\nexpected: %v\ngot: %v\n", k, v, entry[k])
+		}
+	}
+}
+
+func TestAccessLogWithoutWrite(t *testing.T) {
+	a, buf := newTestAccessLogAPI(CommonLog)
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	a.ServeHTTP(httptest.NewRecorder(), newTestAccessLogRequest())
+
+	if !regexp.MustCompile(`" 200 -\n$`).Match(buf.Bytes()) {
+		t.Errorf("access log should have 200 and - if nothing is written, but got %s", buf.Bytes())
+	}
+}
@@ -0,0 +1,72 @@
+package dou
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestContext is request-scoped data created by API.ServeHTTP.
+// Plugins, hooks, middlewares and handlers can populate it and read it by FromRequest.
+// It is shared by pointer, so values set in BeforeDispatch or Handler can be read in AfterDispatch and OnPanic.
+type RequestContext struct {
+	RequestID string       // request id. It is assigned if API.RequestIDHeader is set.
+	Principal interface{}  // authenticated principal. It is set by application.
+	Start     time.Time    // time when API.ServeHTTP started
+	Plugin    Plugin       // plugin that serves the request
+	Route     string       // pattern of the route matched by Router
+	APIStatus int          // api status written by API.APIStatus
+	Panic     *Panic       // panic recovered in API.ServeHTTP. It is set before calling API.OnPanic.
+	Logger    *slog.Logger // API.Logger. Use LoggerFor to log with request attributes.
+
+	notAcceptable bool // no plugin is acceptable. see API.dispatch
+	api           *API // API serving the request. see ReportWriteError
+
+	mu     sync.Mutex
+	values map[interface{}]interface{}
+}
+
+type requestContextKey struct{}
+
+// NewRequestContext returns ctx that carries rc.
+func NewRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc)
+}
+
+// FromContext returns RequestContext carried by ctx, or nil.
+func FromContext(ctx context.Context) *RequestContext {
+	rc, _ := ctx.Value(requestContextKey{}).(*RequestContext)
+	return rc
+}
+
+// FromRequest returns RequestContext of r, or nil if r is nil or not served by API.
+func FromRequest(r *http.Request) *RequestContext {
+	if r == nil {
+		return nil
+	}
+
+	return FromContext(r.Context())
+}
+
+// Set stores value for key.
+// As same as context.WithValue, key should be comparable and should not be built-in type.
+func (rc *RequestContext) Set(key, value interface{}) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.values == nil {
+		rc.values = map[interface{}]interface{}{}
+	}
+
+	rc.values[key] = value
+}
+
+// Get returns value stored for key, or nil.
+func (rc *RequestContext) Get(key interface{}) interface{} {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.values[key]
+}
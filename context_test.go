@@ -0,0 +1,73 @@
+package dou
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type principalKey struct{}
+
+func TestRequestContextIsSharedInRequestLifecycle(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a := newTestAPI()
+	a.LogStackTrace = false
+
+	a.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		rc := FromRequest(r)
+
+		if rc == nil {
+			t.Fatal("FromRequest should return RequestContext in BeforeDispatch")
+		}
+
+		if rc.Start.IsZero() || rc.Plugin != a.Plugin {
+			t.Error("API.ServeHTTP should set Start and Plugin to RequestContext")
+		}
+
+		rc.Principal = "ToQoz"
+		return w, r
+	}
+
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromRequest(r).Set(principalKey{}, "admin")
+		panic("<test panic>")
+	})
+
+	var onPanicRC, afterDispatchRC *RequestContext
+
+	a.OnPanic = func(w http.ResponseWriter, r *http.Request) {
+		onPanicRC = FromRequest(r)
+	}
+
+	a.AfterDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		afterDispatchRC = FromRequest(r)
+		return w, r
+	}
+
+	a.ServeHTTP(response, request)
+
+	for name, rc := range map[string]*RequestContext{"OnPanic": onPanicRC, "AfterDispatch": afterDispatchRC} {
+		if rc == nil {
+			t.Errorf("FromRequest should return RequestContext in %s", name)
+			continue
+		}
+
+		if rc.Principal != "ToQoz" || rc.Get(principalKey{}) != "admin" {
+			t.Errorf("%s should read values set in BeforeDispatch and Handler, but got %v and %v", name, rc.Principal, rc.Get(principalKey{}))
+		}
+	}
+}
+
+func TestFromRequestReturnsNilOutsideAPI(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+
+	if FromRequest(request) != nil {
+		t.Error("FromRequest should return nil if request is not served by API")
+	}
+
+	if FromRequest(nil) != nil {
+		t.Error("FromRequest should return nil if request is nil")
+	}
+}
@@ -0,0 +1,122 @@
+package dou
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is default API.MaxBodyBytes set by NewAPI.
+const DefaultMaxBodyBytes = 1 << 20
+
+var (
+	// ErrUnsupportedMediaType is wrapped by DecodeError when no plugin accepts the request Content-Type.
+	ErrUnsupportedMediaType = errors.New("github.com/ToQoz/dou: unsupported media type")
+
+	// ErrBodyTooLarge is wrapped by DecodeError when the request body is larger than API.MaxBodyBytes.
+	ErrBodyTooLarge = errors.New("github.com/ToQoz/dou: request body too large")
+)
+
+// DecodeError is returned by API.Decode.
+// StatusCode is http status code that should be responded for this error.
+//
+//	ErrUnsupportedMediaType -> 415 Unsupported Media Type
+//	ErrBodyTooLarge         -> 413 Request Entity Too Large
+//	others                  -> 400 Bad Request
+type DecodeError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("github.com/ToQoz/dou: fail to decode request body (%d): %v", e.StatusCode, e.Err)
+}
+
+// Unwrap returns underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decode reads request body and decodes it to v.
+// The body is read up to API.MaxBodyBytes (DefaultMaxBodyBytes if it is zero, no limit if it is negative).
+// If plugin implements MediaTyper, request Content-Type should be one of MediaTypes.
+// Decoding procedure will be implemented by plugin.
+// It returns *DecodeError when fail.
+func (api *API) Decode(r *http.Request, v interface{}) error {
+	plugin, err := api.pluginForContentType(r.Header.Get("Content-Type"))
+
+	if err != nil {
+		return &DecodeError{StatusCode: http.StatusUnsupportedMediaType, Err: err}
+	}
+
+	if r.Body == nil {
+		return &DecodeError{StatusCode: http.StatusBadRequest, Err: io.EOF}
+	}
+
+	body := io.Reader(r.Body)
+	limit := api.MaxBodyBytes
+
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	if limit >= 0 {
+		if r.ContentLength > limit {
+			return &DecodeError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+		}
+
+		body = io.LimitReader(body, limit+1)
+	}
+
+	data, err := io.ReadAll(body)
+
+	if err != nil {
+		return &DecodeError{StatusCode: http.StatusBadRequest, Err: err}
+	}
+
+	if limit >= 0 && int64(len(data)) > limit {
+		return &DecodeError{StatusCode: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+	}
+
+	err = plugin.Unmarshal(data, v)
+
+	if err != nil {
+		return &DecodeError{StatusCode: http.StatusBadRequest, Err: err}
+	}
+
+	return nil
+}
+
+// pluginForContentType returns plugin that accepts contentType.
+// Plugin that doesn't implement MediaTyper accepts any content type.
+// Empty contentType is accepted by api.Plugin.
+func (api *API) pluginForContentType(contentType string) (Plugin, error) {
+	if contentType == "" {
+		return api.Plugin, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	for _, plugin := range append([]Plugin{api.Plugin}, api.Plugins...) {
+		mt, ok := plugin.(MediaTyper)
+
+		if !ok {
+			return plugin, nil
+		}
+
+		for _, t := range mt.MediaTypes() {
+			if strings.EqualFold(t, mediaType) {
+				return plugin, nil
+			}
+		}
+	}
+
+	return nil, ErrUnsupportedMediaType
+}
@@ -0,0 +1,123 @@
+package dou
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestDecodeAPI() *API {
+	Register("testjson", &mediaTypedAPI{name: "json", mediaTypes: []string{"application/json"}})
+
+	defer Deregister("testjson")
+
+	a, err := NewAPI("testjson")
+
+	if err != nil {
+		panic(err)
+	}
+
+	testAPIUnmarshal = func(data []byte, v interface{}) error {
+		return json.Unmarshal(data, v)
+	}
+
+	return a
+}
+
+func TestDecode(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name": "ToQoz"}`))
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	a := newTestDecodeAPI()
+
+	v := map[string]string{}
+	expected := map[string]string{"name": "ToQoz"}
+
+	if err := a.Decode(request, &v); err != nil {
+		t.Fatalf("API.Decode should not return error, but got %v", err)
+	}
+
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("fail to Decode.\nexpected: %v\ngot: %v\n", expected, v)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		contentType  string
+		body         string
+		maxBodyBytes int64
+		expectedCode int
+		expectedErr  error
+	}{
+		{"text/plain", `{}`, DefaultMaxBodyBytes, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType},
+		{"invalid content type", `{}`, DefaultMaxBodyBytes, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType},
+		{"application/json", `{"name": "ToQoz"}`, 4, http.StatusRequestEntityTooLarge, ErrBodyTooLarge},
+		{"application/json", `{`, DefaultMaxBodyBytes, http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		request.Header.Set("Content-Type", test.contentType)
+
+		a := newTestDecodeAPI()
+		a.MaxBodyBytes = test.maxBodyBytes
+
+		err := a.Decode(request, &map[string]string{})
+
+		var de *DecodeError
+
+		if !errors.As(err, &de) {
+			t.Errorf("API.Decode should return *DecodeError, but got %#v", err)
+			continue
+		}
+
+		if de.StatusCode != test.expectedCode {
+			t.Errorf("Content-Type: %q, body: %q\nexpected: %v\ngot: %v\n", test.contentType, test.body, test.expectedCode, de.StatusCode)
+		}
+
+		if test.expectedErr != nil && !errors.Is(err, test.expectedErr) {
+			t.Errorf("Content-Type: %q, body: %q\nexpected: %v\ngot: %v\n", test.contentType, test.body, test.expectedErr, err)
+		}
+	}
+}
+
+func TestDecodeDetectsTooLargeBodyWithoutContentLength(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name": "ToQoz"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.ContentLength = -1
+
+	a := newTestDecodeAPI()
+	a.MaxBodyBytes = 4
+
+	if err := a.Decode(request, &map[string]string{}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("API.Decode should limit body even if Content-Length is unknown\nexpected: %v\ngot: %v\n", ErrBodyTooLarge, err)
+	}
+}
+
+func TestDecodeMaxBodyBytes(t *testing.T) {
+	body := `{"name": "` + strings.Repeat("a", DefaultMaxBodyBytes) + `"}`
+
+	tests := []struct {
+		maxBodyBytes int64
+		expectedErr  error
+	}{
+		{0, ErrBodyTooLarge},
+		{-1, nil},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+
+		a := newTestDecodeAPI()
+		a.MaxBodyBytes = test.maxBodyBytes
+
+		if err := a.Decode(request, &map[string]string{}); !errors.Is(err, test.expectedErr) {
+			t.Errorf("MaxBodyBytes: %v\nexpected: %v\ngot: %v\n", test.maxBodyBytes, test.expectedErr, err)
+		}
+	}
+}
@@ -10,44 +10,16 @@ Simple usage. If you want to see more example, check github.com/ToQoz/dou/exampl
 	package main
 
 	import (
-		"errors"
 		"github.com/ToQoz/dou"
 		_ "github.com/ToQoz/dou/jsonapi"
 		"log"
 		"net"
 		"net/http"
 		"os"
-		"os/signal"
 		"time"
 	)
 
-	// --- API Error type ---
-
-	type apiError struct {
-		Message string `json:"message"`
-	}
-
-	func newAPIError(err error) *apiError {
-		return &apiError{Message: err.Error()}
-	}
-
-	type apiErrors struct {
-		Errors []*apiError `json:"errors"`
-	}
-
-	func newAPIErrors(errs []error) *apiErrors {
-		aErrs := &apiErrors{}
-
-		for _, err := range errs {
-			aErrs.Errors = append(aErrs.Errors, newAPIError(err))
-		}
-
-		return aErrs
-	}
-
 	func main() {
-		defer teardown()
-
 		api, err := dou.NewAPI("jsonapi")
 		if err != nil {
 			log.Fatal(err)
@@ -63,18 +35,25 @@ Simple usage. If you want to see more example, check github.com/ToQoz/dou/exampl
 			case "/":
 				api.Ok(w, map[string]string{"hello": "world"}, http.StatusOK)
 			case "/error":
-				err := errors.New("some error occur")
-				api.Error(w, newAPIError(err), http.StatusInternalServerError)
+				api.Fail(w, &dou.Error{HTTPStatus: http.StatusInternalServerError, Message: "some error occur"})
 			case "/errors":
-				var errs []error
-				errs = append(errs, errors.New("1 error occur"))
-				errs = append(errs, errors.New("2 error occur"))
-				api.Error(w, newAPIErrors(errs), http.StatusInternalServerError)
+				api.Fail(w, &dou.Error{
+					HTTPStatus: http.StatusInternalServerError,
+					Message:    "some errors occur",
+					Details:    []dou.FieldError{{Message: "1 error occur"}, {Message: "2 error occur"}},
+				})
 			default:
-				api.Error(w, map[string]string{"message": http.StatusText(http.StatusNotFound)}, http.StatusNotFound)
+				api.Fail(w, dou.NewError(http.StatusNotFound, "not_found", ""))
 			}
 		})
 
+		// --- Teardown ---
+		api.AddTeardown(func() error {
+			log.Print("Tearing down...")
+			log.Print("Finished - bye bye.  ;-)")
+			return nil
+		})
+
 		// --- Create listener ---
 		// You can use utility, for example github.com/lestrrat/go-server-starter-listener etc.
 		l, err := net.Listen("tcp", ":8099")
@@ -85,32 +64,8 @@ Simple usage. If you want to see more example, check github.com/ToQoz/dou/exampl
 
 		log.Printf("Listen: %s", ":8099")
 
-		// --- Handle C-c ---
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-
-		go func() {
-			for sig := range c {
-				log.Print("Stopping the server...")
-
-				switch sig {
-				case os.Interrupt:
-					// --- Stop Server ---
-					api.Stop()
-					return
-				default:
-					log.Print("Receive unknown signal...")
-				}
-			}
-		}()
-
-		// --- Run Server ---
-		api.Run(l)
-	}
-
-	func teardown() {
-		log.Print("Tearing down...")
-		log.Print("Finished - bye bye.  ;-)")
+		// --- Run Server until C-c ---
+		os.Exit(api.RunUntilSignal(l))
 	}
 
 You can creating a custom plugin in accordance with your api type or domain-specific use-case.
@@ -124,5 +79,20 @@ The plugin should keep following interface.
 		Unmarshal(data []byte, v interface{}) error
 		APIStatus(w http.ResponseWriter, code int)
 	}
+
+Register makes a plugin shared by all APIs. RegisterFactory makes a factory that creates a plugin for each API from its Config.
+
+	api, err := dou.NewAPIWithConfig("xmlapi", dou.Config{"indent": "  "})
+
+Built-in plugins are github.com/ToQoz/dou/jsonapi ("jsonapi") and github.com/ToQoz/dou/problemjson ("problemjson").
+problemjson renders API.Error and OnPanic bodies as RFC 7807 application/problem+json.
+A plugin implementing ErrorMarshaler can render error responses in another format than Ok like this.
+
+A plugin implementing PanicHandler receives the recovered panic value, the full stack trace and the phase that panicked.
+
+A plugin implementing MediaTyper can be selected per request by Accept header.
+NewNegotiatingAPI creates API that negotiates between registered plugins.
+
+	api, err := dou.NewNegotiatingAPI("jsonapi", "jsonapi", "xmlapi")
 */
 package dou
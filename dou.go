@@ -1,17 +1,35 @@
 package dou
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
-	"runtime"
-	"strings"
+	"runtime/debug"
+	"sync"
 	"time"
 )
 
 var (
-	plugins = map[string]Plugin{}
+	pluginsMu sync.RWMutex
+	plugins   = map[string]*registration{}
+)
+
+var (
+	// ErrServerStopped is returned by API.Run when the server was stopped by API.Stop, API.Shutdown or closing API.Listener.
+	ErrServerStopped = errors.New("github.com/ToQoz/dou: server stopped")
+
+	// ErrNotRunning is returned by API.Shutdown, API.Stop and API.Restart when API.Run is not serving. e.g. before Run or after Run returned.
+	ErrNotRunning = errors.New("github.com/ToQoz/dou: API is not running")
+
+	// ErrNilHandler is returned by API.Run when API.Handler is nil.
+	ErrNilHandler = errors.New("github.com/ToQoz/dou: API.Handler should not be nil")
+
+	// ErrNoListener is returned by API.RunListeners when no listener is given.
+	ErrNoListener = errors.New("github.com/ToQoz/dou: no listener to serve")
 )
 
 // Config can store configuration map for API.
@@ -30,26 +48,102 @@ type Plugin interface {
 	APIStatus(w http.ResponseWriter, code int)
 }
 
+// ErrorMarshaler is implemented by Plugin that renders error response in another format than Ok.
+// API.Error calls MarshalError instead of Marshal if Plugin implements this.
+// MarshalError may set headers such as Content-Type to w. They are written with httpStatusCode.
+type ErrorMarshaler interface {
+	MarshalError(w http.ResponseWriter, v interface{}, httpStatusCode int) ([]byte, error)
+}
+
 // SafeWriter is safe http.ResponseWriter
 // For prevent unintentionally multiple calling http.ResponseWriter.Write, this has bool `Worte`.
 // When recovering panic, this is useful for prevent unintentionally writing to the continuation that was written before panic.
 // Example: Write([]byte(`[]`)) ->
-//          SomeFunc() -> (panic) -> OnPanic() ->
-//          Write(`{"message": "Internal server error"}`)
+//
+//	SomeFunc() -> (panic) -> OnPanic() ->
+//	Write(`{"message": "Internal server error"}`)
+//
 // In ideal theory that I think, we have to prevent panic after calling Write. But no accident, no life :)
+//
+// SafeWriter also records the response. AfterDispatch and plugins can read them through SafeWriterOf.
+// Superfluous WriteHeader calls are ignored, so OnPanic can't break status code written before panic.
+//
+// API.ServeHTTP passes SafeWriter that implements exactly the optional interfaces
+// (http.Flusher, http.Hijacker, http.CloseNotifier, io.ReaderFrom and http.Pusher) that the underlying writer implements.
 type SafeWriter struct {
 	Wrote bool
 	http.ResponseWriter
+
+	WroteHeader bool      // true after headers are sent
+	Status      int       // http status code sent. 0 until headers are sent. 101 if hijacked before sending headers
+	Written     int64     // bytes of body written
+	FirstWrite  time.Time // time when headers are sent first
+	Hijacked    bool      // true after the connection is hijacked
+
+	plugin Plugin        // negotiated plugin for this response
+	req    *http.Request // request of this response for Reporter
 }
 
 // NewSafeWriter new SafeWriter by given http.ResponseWriter
 func NewSafeWriter(w http.ResponseWriter) *SafeWriter {
-	return &SafeWriter{false, w}
+	return &SafeWriter{Wrote: false, ResponseWriter: w}
+}
+
+// Unwrap returns underlying http.ResponseWriter.
+// This enables http.ResponseController to find it.
+func (sw *SafeWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// SafeWriterOf returns SafeWriter that w is or wraps.
+// w is unwrapped by `Unwrap() http.ResponseWriter` method.
+func SafeWriterOf(w http.ResponseWriter) (*SafeWriter, bool) {
+	for w != nil {
+		if sw, ok := w.(*SafeWriter); ok {
+			return sw, true
+		}
+
+		u, ok := w.(interface {
+			Unwrap() http.ResponseWriter
+		})
+
+		if !ok {
+			break
+		}
+
+		w = u.Unwrap()
+	}
+
+	return nil, false
+}
+
+// WriteHeader sends headers with status code once.
+// Informational 1xx status codes except 101 Switching Protocols may be sent before it.
+func (sw *SafeWriter) WriteHeader(code int) {
+	if sw.WroteHeader {
+		return
+	}
+
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		sw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	sw.WroteHeader = true
+	sw.Status = code
+	sw.FirstWrite = time.Now()
+	sw.ResponseWriter.WriteHeader(code)
 }
 
 func (sw *SafeWriter) Write(p []byte) (int, error) {
+	if !sw.WroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+
 	sw.Wrote = true
-	return sw.ResponseWriter.Write(p)
+	n, err := sw.ResponseWriter.Write(p)
+	sw.Written += int64(n)
+	return n, err
 }
 
 // API is the bone of dou.
@@ -60,11 +154,68 @@ type API struct {
 	Config        Config
 	Listener      net.Listener
 	Plugin        Plugin
-	LogStackTrace bool // Log stack trace when panic occur
+	LogStackTrace bool // Log panic with stack trace by Logger when panic occur and Reporter is nil. Reporter always receives panics.
+
+	// Reporter receives panics and errors of writing response.
+	// If this is nil, they are logged by Logger.
+	Reporter Reporter
+
+	// RequestIDHeader enables request id if it is not empty. e.g. DefaultRequestIDHeader
+	// Valid request id in this request header is honoured, otherwise new one is generated.
+	// The request id is set to RequestContext.RequestID and echoed in this response header.
+	RequestIDHeader string
+
+	// GenerateRequestID generates request id. If this is nil, NewRequestID is used.
+	GenerateRequestID func() string
 
-	ReadTimeout    time.Duration // for http.Server
-	WriteTimeout   time.Duration // for http.Server
-	MaxHeaderBytes int           // for http.Server
+	// Metrics collects traffic of API if it is not nil.
+	Metrics *Metrics
+
+	// AccessLogger writes access log of each request if it is not nil.
+	AccessLogger *AccessLogger
+
+	// Logger emits structured records for panics, write errors and server start/stop.
+	// If this is nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// Plugins are candidates of content negotiation by Accept header.
+	// If this is empty, API.Plugin is always used.
+	// Otherwise API.Plugin is used as default and for responding 406 Not Acceptable.
+	// see also NewNegotiatingAPI
+	Plugins []Plugin
+
+	ReadTimeout       time.Duration // for http.Server
+	ReadHeaderTimeout time.Duration // for http.Server
+	WriteTimeout      time.Duration // for http.Server
+	IdleTimeout       time.Duration // for http.Server
+	MaxHeaderBytes    int           // for http.Server
+
+	ErrorLog    *log.Logger                                     // for http.Server
+	ConnState   func(net.Conn, http.ConnState)                  // for http.Server
+	BaseContext func(net.Listener) context.Context              // for http.Server
+	ConnContext func(context.Context, net.Conn) context.Context // for http.Server
+	HTTP2       *http.HTTP2Config                               // for http.Server
+	Protocols   *http.Protocols                                 // for http.Server
+
+	// DisableKeepAlives disables HTTP keep-alives. Every connection is closed after one request.
+	DisableKeepAlives bool
+
+	// ConfigureServer is called with http.Server created by API.Run before serving.
+	// Use this to tune the other settings of http.Server. Handler should not be replaced.
+	ConfigureServer func(server *http.Server)
+
+	// MaxBodyBytes limits request body size read by API.Decode.
+	// Zero means DefaultMaxBodyBytes, and negative means no limit.
+	// This will be set DefaultMaxBodyBytes in NewAPI.
+	MaxBodyBytes int64
+
+	// ShutdownTimeout limits how long API.Stop waits for in-flight requests.
+	// Zero means waiting until all of them finish.
+	ShutdownTimeout time.Duration
+
+	// ShutdownDelay is time to keep accepting requests after ReadinessHandler starts failing in API.Shutdown.
+	// It gives load balancers time to stop sending traffic before the listener is closed.
+	ShutdownDelay time.Duration
 
 	// You change BeforeDispatch behavior that provided by plugin overriding this.
 	// This will be set default func in NewAPI. It simply call Plugin.BeforeDispatch()
@@ -82,83 +233,145 @@ type API struct {
 	// It will be implemented by api.Plugin.
 	// e.g. github.com/ToQoz/dou/jsonapi Use "X-API-Status" header.
 	APIStatus func(w http.ResponseWriter, code int)
+
+	middlewares []func(http.Handler) http.Handler
+	chain       http.Handler // API.dispatch wrapped by middlewares. see API.Use
+	chainOnce   sync.Once
+
+	mu              sync.Mutex
+	server          *http.Server
+	listeners       []net.Listener // served by RunListeners. see Restart
+	drained         chan struct{}  // closed when Shutdown finished draining
+	once            *sync.Once
+	shuttingDown    bool // true after Shutdown began. see ReadinessHandler
+	readinessChecks []readinessCheck
+	teardowns       []func() error
+
+	startHooks           []func(addr net.Addr) error
+	stopHooks            []func(ctx context.Context) error
+	shutdownTimeoutHooks []func()
 }
 
 // Register makes a database driver available by the provided name.
+// It panics if plugin is nil or Register is called twice for the same name. see also RegisterPlugin
 func Register(pluginName string, plugin Plugin) {
-	if plugin == nil {
-		panic("github.com/ToQoz/dou: Register plugin is nil")
-	}
+	err := RegisterPlugin(pluginName, plugin)
 
-	if _, dup := plugins[pluginName]; dup {
+	switch err {
+	case ErrNilPlugin:
+		panic("github.com/ToQoz/dou: Register plugin is nil")
+	case ErrDuplicatePlugin:
 		panic("github.com/ToQoz/dou: Register called twice for plugin " + pluginName)
 	}
-
-	plugins[pluginName] = plugin
 }
 
 // Deregister plgugin that registered by the provided name.
+// It logs a warning if the plugin is not registered. see also DeregisterPlugin
 func Deregister(pluginName string) {
-	_, ok := plugins[pluginName]
+	err := DeregisterPlugin(pluginName)
 
-	if !ok {
-		log.Printf("github.com/ToQoz/dou: plugin %v is not registered. Can't deregister", pluginName)
-		return
+	if err != nil {
+		slog.Warn("plugin is not registered. Can't deregister", slog.String("plugin", pluginName))
 	}
-
-	delete(plugins, pluginName)
 }
 
 // NewAPI new and initialize API.
 func NewAPI(pluginName string) (*API, error) {
-	plugin, ok := plugins[pluginName]
+	return NewAPIWithConfig(pluginName, Config{})
+}
 
-	if !ok {
-		return nil, fmt.Errorf("github.com/ToQoz/dou: unknown plugin %q (forgotten import?)", pluginName)
+// NewAPIWithConfig new and initialize API with config.
+// Plugin registered by RegisterFactory is created with config, and its error is returned.
+func NewAPIWithConfig(pluginName string, config Config) (*API, error) {
+	if config == nil {
+		config = Config{}
+	}
+
+	plugin, err := newPlugin(pluginName, config)
+
+	if err != nil {
+		return nil, err
 	}
 
 	api := new(API)
-	api.Config = Config{}
+	api.Config = config
 	api.Plugin = plugin
 	api.LogStackTrace = true
+	api.MaxBodyBytes = DefaultMaxBodyBytes
 
 	api.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
-		return api.Plugin.BeforeDispatch(w, r)
+		return api.PluginFor(w).BeforeDispatch(w, r)
 	}
 
 	api.AfterDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
-		return api.Plugin.AfterDispatch(w, r)
+		return api.PluginFor(w).AfterDispatch(w, r)
 	}
 
 	api.OnPanic = func(w http.ResponseWriter, r *http.Request) {
-		api.Plugin.OnPanic(w, r)
+		plugin := api.PluginFor(w)
+
+		if ph, ok := plugin.(PanicHandler); ok {
+			if rc := FromRequest(r); rc != nil && rc.Panic != nil {
+				ph.HandlePanic(w, r, rc.Panic)
+				return
+			}
+		}
+
+		plugin.OnPanic(w, r)
 	}
 
 	api.APIStatus = func(w http.ResponseWriter, code int) {
-		api.Plugin.APIStatus(w, code)
+		if sw, ok := SafeWriterOf(w); ok && sw.req != nil {
+			FromRequest(sw.req).APIStatus = code
+		}
+
+		api.PluginFor(w).APIStatus(w, code)
 	}
 
 	return api, nil
 }
 
-// ServeHTTP calls
-//     1. call BeforeDispatch()
-//     2. call Router.ServeHTTP()
-//     3. call AfterDispatch()
+// ServeHTTP creates RequestContext for the request, and calls
+//  1. call BeforeDispatch()
+//  2. call middlewares registered by Use() and Router.ServeHTTP()
+//  3. call AfterDispatch()
+//  4. collect Metrics and write access log by AccessLogger
+//
 // And call OnPanic when panic occur.
 // if panic occur before calling API.AfterDispatch, this call it after recovering.
 func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sw := NewSafeWriter(w)
+	w = sw.wrap()
+	handler := api.handler()
+	acceptable := true
+
+	if len(api.Plugins) > 0 {
+		// Response format depends on Accept header. Caches should not mix them.
+		w.Header().Add("Vary", "Accept")
+		sw.plugin, acceptable = api.negotiate(r)
+	}
+
+	rc := &RequestContext{Start: time.Now(), Plugin: api.PluginFor(sw), Logger: api.logger(), notAcceptable: !acceptable, api: api}
+	r = r.WithContext(NewRequestContext(r.Context(), rc))
+	sw.req = r
+
+	if api.RequestIDHeader != "" {
+		api.assignRequestID(sw, r, rc)
+	}
+
+	if api.Metrics != nil {
+		api.Metrics.begin()
+		defer api.Metrics.end(sw, rc)
+	}
+
+	var phase Phase
 
-	// OnPanic if occur panic in API.BeforeDispatch or Router.ServeHTTP
+	// OnPanic if occur panic in API.BeforeDispatch, middlewares or Router.ServeHTTP
 	recoverFuncIfPanicOccur := func() {
 		if recv := recover(); recv != nil {
-			if api.LogStackTrace {
-				stacktrace := make([]byte, 2048)
-				runtime.Stack(stacktrace, false)
+			rc.Panic = &Panic{Value: recv, Stack: debug.Stack(), Phase: phase}
 
-				log.Printf("github.com/ToQoz/dou: OnPanic panic in API.ServeHTTP: %s\n%s", recv, stacktrace)
-			}
+			api.reportPanic(r, rc.Panic)
 
 			api.OnPanic(sw, r)
 		}
@@ -171,14 +384,21 @@ func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	func() {
 		defer recoverFuncIfPanicOccur()
-		w, r = api.BeforeDispatch(sw, r)
-		api.Handler.ServeHTTP(w, r)
+		phase = PhaseBeforeDispatch
+		w, r = api.BeforeDispatch(w, r)
+		phase = PhaseHandler
+		handler.ServeHTTP(w, r)
 	}()
 
 	func() {
 		defer recoverFuncIfPanicOccur()
+		phase = PhaseAfterDispatch
 		api.AfterDispatch(w, r)
 	}()
+
+	if api.AccessLogger != nil {
+		api.AccessLogger.Log(sw, sw.req)
+	}
 }
 
 // ----------------------------------------------------------------------------
@@ -192,7 +412,7 @@ func (api *API) Ok(w http.ResponseWriter, resource interface{}, httpStatusCode i
 		httpStatusCode = http.StatusOK
 	}
 
-	b, err := api.Marshal(resource)
+	b, err := api.PluginFor(w).Marshal(resource)
 
 	if err != nil {
 		// Unexpected error.
@@ -210,8 +430,8 @@ func (api *API) Ok(w http.ResponseWriter, resource interface{}, httpStatusCode i
 	if err != nil {
 		// Skip this error.
 		// http.Error skip too.
-		// Only warn.
-		log.Printf("github.com/ToQoz/dou: fail to http.ResponseWriter.Write([]byte)\n%v", err)
+		// Only report.
+		api.reportWriteError(w, err)
 		return
 	}
 }
@@ -219,12 +439,22 @@ func (api *API) Ok(w http.ResponseWriter, resource interface{}, httpStatusCode i
 // Error marshals and writes resource with http status code.
 // Use this when you want to return error response.
 // This is almost same as api.Ok except NAME(Ok, Error).
+// But if api.Plugin implements ErrorMarshaler, resource is marshaled by it.
 func (api *API) Error(w http.ResponseWriter, resource interface{}, httpStatusCode int) {
 	if httpStatusCode == 0 {
 		httpStatusCode = http.StatusInternalServerError
 	}
 
-	b, err := api.Marshal(resource)
+	var (
+		b   []byte
+		err error
+	)
+
+	if em, ok := api.PluginFor(w).(ErrorMarshaler); ok {
+		b, err = em.MarshalError(w, resource, httpStatusCode)
+	} else {
+		b, err = api.PluginFor(w).Marshal(resource)
+	}
 
 	if err != nil {
 		// Unexpected error.
@@ -242,12 +472,22 @@ func (api *API) Error(w http.ResponseWriter, resource interface{}, httpStatusCod
 	if err != nil {
 		// Skip this error.
 		// http.Error skip too.
-		// Only warn.
-		log.Printf("github.com/ToQoz/dou: fail to fmt.Fpintln(http.ResponseWriter, string)\n%v", err)
+		// Only report.
+		api.reportWriteError(w, err)
 		return
 	}
 }
 
+// PluginFor returns Plugin that is responsible for the response written to w.
+// This is API.Plugin unless another plugin is negotiated for the request.
+func (api *API) PluginFor(w http.ResponseWriter) Plugin {
+	if sw, ok := SafeWriterOf(w); ok && sw.plugin != nil {
+		return sw.plugin
+	}
+
+	return api.Plugin
+}
+
 // ----------------------------------------------------------------------------
 // Export Plugin's func `Marshal/Unmarshal`. They has possibility to be used from outside of API.
 // ----------------------------------------------------------------------------
@@ -269,39 +509,203 @@ func (api *API) Unmarshal(data []byte, v interface{}) error {
 // ----------------------------------------------------------------------------
 
 // Run api server.
-// If fail to serve listener, output error and exit.
-func (api *API) Run(l net.Listener) {
+// Run always returns a non-nil error.
+// When API.Shutdown or API.Stop is called, Run returns ErrServerStopped after in-flight requests are drained and stop hooks finished.
+// Other errors mean that serving listener failed or a start hook failed.
+// see also API.OnStart and API.OnStop
+func (api *API) Run(l net.Listener) error {
+	return api.RunListeners(l)
+}
+
+// RunListeners runs api server on all of ls simultaneously. e.g. public TLS listener created by TLSListener and internal unix socket.
+// API.Listener is set to the first one.
+// API.Shutdown and API.Stop stop all of them together. If one of them fails, the others are closed and its error is returned.
+// Otherwise this returns ErrServerStopped like Run.
+func (api *API) RunListeners(ls ...net.Listener) error {
 	if api.Handler == nil {
-		panic("github.com/ToQoz/dou: API.Handler should not be nil")
+		return ErrNilHandler
+	}
+
+	if len(ls) == 0 {
+		return ErrNoListener
+	}
+
+	server := api.newServer()
+
+	for _, l := range ls {
+		err := api.runStartHooks(l.Addr())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	drained := make(chan struct{})
+	once := new(sync.Once)
+
+	api.mu.Lock()
+	api.Listener = ls[0]
+	api.listeners = ls
+	api.server = server
+	api.drained = drained
+	api.once = once
+	api.shuttingDown = false
+	api.mu.Unlock()
+
+	errs := make(chan error, len(ls))
+
+	for _, l := range ls {
+		go func(l net.Listener) {
+			errs <- api.serve(server, l, drained)
+		}(l)
+	}
+
+	var err error
+
+	for range ls {
+		e := <-errs
+
+		if e != ErrServerStopped && err == nil {
+			err = e
+
+			// Stop the others together.
+			server.Close()
+			once.Do(func() {
+				api.runStopHooks(context.Background())
+				close(drained)
+			})
+		}
+	}
+
+	if err == nil {
+		err = ErrServerStopped
+	}
+
+	api.mu.Lock()
+	if api.server == server {
+		// Not serving any more. Shutdown, Stop and Restart return ErrNotRunning.
+		api.server = nil
+		api.listeners = nil
 	}
+	api.mu.Unlock()
 
-	api.Listener = l
+	return err
+}
 
+// newServer creates http.Server configured by api.
+func (api *API) newServer() *http.Server {
 	server := &http.Server{
-		Handler:        api,
-		ReadTimeout:    api.ReadTimeout,
-		WriteTimeout:   api.WriteTimeout,
-		MaxHeaderBytes: api.MaxHeaderBytes,
+		Handler:           api,
+		ReadTimeout:       api.ReadTimeout,
+		ReadHeaderTimeout: api.ReadHeaderTimeout,
+		WriteTimeout:      api.WriteTimeout,
+		IdleTimeout:       api.IdleTimeout,
+		MaxHeaderBytes:    api.MaxHeaderBytes,
+		ErrorLog:          api.ErrorLog,
+		ConnState:         api.ConnState,
+		BaseContext:       api.BaseContext,
+		ConnContext:       api.ConnContext,
+		HTTP2:             api.HTTP2,
+		Protocols:         api.Protocols,
 	}
 
-	err := server.Serve(api.Listener)
+	if api.DisableKeepAlives {
+		server.SetKeepAlivesEnabled(false)
+	}
 
-	if err != nil {
-		// skip error `http.errClosing`
-		// Excuse:
-		//     net.Listener.Close() closeing listener, but doesn't stop http.Server.Serve() loop.
-		//     So net.Listener.Accept() in http.Server.Serve() return error `http.errClosing`.
-		// This approach is unstable because of depending on not public error type but private error message.
-		// But if it is changed, this occur panic. So we can notice.
-		if strings.Contains(err.Error(), "use of closed network connection") {
-			return
+	if api.ConfigureServer != nil {
+		api.ConfigureServer(server)
+	}
+
+	return server
+}
+
+// serve serves l by server and logs its lifecycle.
+func (api *API) serve(server *http.Server, l net.Listener, drained chan struct{}) error {
+	api.logger().Info("server started", slog.String("addr", l.Addr().String()))
+
+	err := server.Serve(l)
+
+	switch {
+	case err == http.ErrServerClosed:
+		<-drained
+		err = ErrServerStopped
+	case errors.Is(err, net.ErrClosed):
+		// API.Listener was closed directly.
+		err = ErrServerStopped
+	}
+
+	if err == ErrServerStopped {
+		api.logger().Info("server stopped", slog.String("addr", l.Addr().String()))
+	} else {
+		api.logger().Error("server failed", slog.String("addr", l.Addr().String()), slog.Any("error", err))
+	}
+
+	return err
+}
+
+// Shutdown stops api server gracefully.
+// It stops accepting new connections, closes idle keep-alive connections and waits for in-flight requests.
+// If ctx is done before draining completed, shutdown timeout hooks are called, remaining connections are closed and ctx.Err() is returned.
+// So nil means all in-flight requests were completed.
+// Then stop hooks are called with context that has own deadline (see API.OnStop). Their errors are joined to the returned error.
+func (api *API) Shutdown(ctx context.Context) error {
+	api.mu.Lock()
+	server, drained, once := api.server, api.drained, api.once
+
+	if server != nil {
+		// Fail readiness before closing listener.
+		api.shuttingDown = true
+	}
+
+	api.mu.Unlock()
+
+	if server == nil {
+		return ErrNotRunning
+	}
+
+	api.logger().Info("server shutting down")
+
+	if api.ShutdownDelay > 0 {
+		t := time.NewTimer(api.ShutdownDelay)
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
 		}
+	}
 
-		panic(err)
+	err := server.Shutdown(ctx)
+
+	if err != nil {
+		// Deadline exceeded. Cut off remaining connections.
+		api.logger().Warn("fail to drain in-flight requests. Closing remaining connections", slog.Any("error", err))
+		api.runShutdownTimeoutHooks()
+		server.Close()
 	}
+
+	once.Do(func() {
+		if hookErr := api.runStopHooks(ctx); hookErr != nil {
+			err = errors.Join(err, hookErr)
+		}
+
+		close(drained)
+	})
+
+	return err
 }
 
-// Stop api server
-func (api *API) Stop() {
-	api.Listener.Close()
+// Stop api server gracefully.
+// This waits in-flight requests for API.ShutdownTimeout at most.
+func (api *API) Stop() error {
+	ctx := context.Background()
+
+	if api.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, api.ShutdownTimeout)
+		defer cancel()
+	}
+
+	return api.Shutdown(ctx)
 }
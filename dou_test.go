@@ -1,13 +1,17 @@
 package dou
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type recorder struct {
@@ -187,6 +191,78 @@ func TestNewSafeWriterWrite(t *testing.T) {
 	}).ServeHTTP(response, request)
 }
 
+func TestSafeWriterRecordsResponse(t *testing.T) {
+	response := httptest.NewRecorder()
+
+	sw := NewSafeWriter(response)
+	sw.WriteHeader(http.StatusCreated)
+	sw.Write([]byte("hello"))
+	sw.Write([]byte(" world"))
+
+	if !sw.WroteHeader || sw.Status != http.StatusCreated {
+		t.Errorf("SafeWriter should record status code\nexpected: %v\ngot: %v\n", http.StatusCreated, sw.Status)
+	}
+
+	if sw.Written != 11 {
+		t.Errorf("SafeWriter should record written bytes\nexpected: %v\ngot: %v\n", 11, sw.Written)
+	}
+
+	if sw.FirstWrite.IsZero() {
+		t.Error("SafeWriter should record time of first write")
+	}
+}
+
+func TestSafeWriterWriteSets200(t *testing.T) {
+	response := httptest.NewRecorder()
+
+	sw := NewSafeWriter(response)
+	sw.Write([]byte("hello"))
+
+	if sw.Status != http.StatusOK {
+		t.Errorf("SafeWriter.Write should send 200 if WriteHeader is not called\nexpected: %v\ngot: %v\n", http.StatusOK, sw.Status)
+	}
+}
+
+func TestSafeWriterIgnoresSuperfluousWriteHeader(t *testing.T) {
+	response := httptest.NewRecorder()
+
+	sw := NewSafeWriter(response)
+	sw.WriteHeader(http.StatusAccepted)
+	sw.WriteHeader(http.StatusInternalServerError)
+
+	if sw.Status != http.StatusAccepted || response.Code != http.StatusAccepted {
+		t.Errorf("SafeWriter should ignore superfluous WriteHeader\nexpected: %v\ngot: %v, %v\n", http.StatusAccepted, sw.Status, response.Code)
+	}
+}
+
+func TestAfterDispatchCanReadResponseStatus(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a := newTestAPI()
+	a.LogStackTrace = false
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("<test panic>")
+	})
+
+	var status int
+
+	a.AfterDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		if sw, ok := SafeWriterOf(w); ok {
+			status = sw.Status
+		}
+
+		return w, r
+	}
+
+	a.ServeHTTP(response, request)
+
+	if status != http.StatusAccepted {
+		t.Errorf("AfterDispatch should be able to read status code\nexpected: %v\ngot: %v\n", http.StatusAccepted, status)
+	}
+}
+
 func TestAPIUnmarshal(t *testing.T) {
 	request, _ := http.NewRequest("GET", `/?json={"name": "ToQoz"}`, nil)
 	response := httptest.NewRecorder()
@@ -328,6 +404,269 @@ func TestAPIErrorSet500IfGiven0AsHTTPStatusCode(t *testing.T) {
 
 }
 
+func TestShutdownWaitsInFlightRequest(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	bodyCh := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/")
+
+		if err != nil {
+			bodyCh <- err.Error()
+			return
+		}
+
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		bodyCh <- string(b)
+	}()
+
+	<-entered
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- a.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdownErr:
+		t.Fatal("API.Shutdown should wait in-flight request")
+	case <-runReturned:
+		t.Fatal("API.Run should not return before in-flight request is drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	if got := <-bodyCh; got != "done" {
+		t.Errorf("in-flight request should be completed\nexpected: %v\ngot: %v\n", "done", got)
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("API.Shutdown should return nil if draining completed, but got %v", err)
+	}
+
+	if err := <-runReturned; err != ErrServerStopped {
+		t.Errorf("API.Run should return ErrServerStopped after API.Shutdown\nexpected: %v\ngot: %v\n", ErrServerStopped, err)
+	}
+}
+
+func TestShutdownReturnsErrorIfDeadlineExceeded(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	a := newTestAPI()
+	a.LogStackTrace = false
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	go a.Run(l)
+	go http.Get("http://" + l.Addr().String() + "/")
+
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := a.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("API.Shutdown should report draining was not completed\nexpected: %v\ngot: %v\n", context.DeadlineExceeded, err)
+	}
+}
+
+func TestShutdownReturnsErrNotRunningBeforeRun(t *testing.T) {
+	a := newTestAPI()
+
+	if err := a.Shutdown(context.Background()); err != ErrNotRunning {
+		t.Errorf("API.Shutdown should return ErrNotRunning before API.Run\nexpected: %v\ngot: %v\n", ErrNotRunning, err)
+	}
+}
+
+type brokenListener struct {
+	net.Listener
+}
+
+var errBrokenListener = errors.New("broken listener")
+
+func (l *brokenListener) Accept() (net.Conn, error) {
+	return nil, errBrokenListener
+}
+
+func TestRunReturnsErrNilHandler(t *testing.T) {
+	a := newTestAPI()
+
+	if err := a.Run(nil); err != ErrNilHandler {
+		t.Errorf("API.Run should return ErrNilHandler if API.Handler is nil\nexpected: %v\ngot: %v\n", ErrNilHandler, err)
+	}
+}
+
+func TestRunReturnsErrServerStoppedIfListenerClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	l.Close()
+
+	if err := <-runReturned; err != ErrServerStopped {
+		t.Errorf("API.Run should return ErrServerStopped if listener is closed\nexpected: %v\ngot: %v\n", ErrServerStopped, err)
+	}
+}
+
+func TestRunReturnsServeError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer l.Close()
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := a.Run(&brokenListener{l}); err != errBrokenListener {
+		t.Errorf("API.Run should return error occurred in serving listener\nexpected: %v\ngot: %v\n", errBrokenListener, err)
+	}
+
+	if err := a.Stop(); err != ErrNotRunning {
+		t.Errorf("API.Stop should return ErrNotRunning after API.Run returned\nexpected: %v\ngot: %v\n", ErrNotRunning, err)
+	}
+
+	if err := a.Restart(); err != ErrNotRunning {
+		t.Errorf("API.Restart should return ErrNotRunning after API.Run returned\nexpected: %v\ngot: %v\n", ErrNotRunning, err)
+	}
+}
+
+func TestRunListenersStopsAllIfOneFails(t *testing.T) {
+	l1, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer l2.Close()
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := a.RunListeners(l1, &brokenListener{l2}); err != errBrokenListener {
+		t.Errorf("API.RunListeners should return error occurred in serving a listener\nexpected: %v\ngot: %v\n", errBrokenListener, err)
+	}
+
+	if _, err := net.Dial("tcp", l1.Addr().String()); err == nil {
+		t.Error("API.RunListeners should close the other listeners if one fails")
+	}
+}
+
+func TestRunListenersReturnsErrNoListener(t *testing.T) {
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := a.RunListeners(); err != ErrNoListener {
+		t.Errorf("API.RunListeners should return ErrNoListener if no listener is given\nexpected: %v\ngot: %v\n", ErrNoListener, err)
+	}
+}
+
+func TestRunConfiguresServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	type ctxKey struct{}
+
+	a := newTestAPI()
+	a.ReadHeaderTimeout = time.Second
+	a.IdleTimeout = 2 * time.Second
+	a.DisableKeepAlives = true
+	a.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), ctxKey{}, "base")
+	}
+
+	var server *http.Server
+
+	a.ConfigureServer = func(s *http.Server) {
+		server = s
+	}
+
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Context().Value(ctxKey{}).(string)))
+	})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	res, err := http.Get("http://" + l.Addr().String() + "/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if string(b) != "base" {
+		t.Errorf("API.BaseContext should be used by server\nexpected: %v\ngot: %v\n", "base", string(b))
+	}
+
+	if !res.Close {
+		t.Error("API.DisableKeepAlives should close connection after response")
+	}
+
+	if server == nil || server.ReadHeaderTimeout != time.Second || server.IdleTimeout != 2*time.Second || server.Handler != a {
+		t.Errorf("API.ConfigureServer should be called with configured server, but got %+v", server)
+	}
+
+	a.Stop()
+	<-runReturned
+}
+
 func newTestAPI() *API {
 	Register("testapi", &testAPI{})
 
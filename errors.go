@@ -0,0 +1,115 @@
+package dou
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Error is error that can be rendered as api response by API.Fail.
+// HTTPStatus and APIStatus are not rendered in body. They are written as http status code and by API.APIStatus.
+// Cause is not rendered too. Because it may leak internals.
+type Error struct {
+	HTTPStatus int          `json:"-"`
+	APIStatus  int          `json:"-"`
+	Code       string       `json:"code,omitempty"`
+	Message    string       `json:"message"`
+	Details    []FieldError `json:"details,omitempty"`
+	Cause      error        `json:"-"`
+}
+
+// FieldError describes error about a field of request. e.g. validation error.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewError new Error by given http status code, machine-readable code and message.
+// If message is empty, http.StatusText(httpStatus) is used.
+func NewError(httpStatus int, code, message string) *Error {
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+
+	return &Error{HTTPStatus: httpStatus, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+
+	if e.Code != "" {
+		msg = e.Code + ": " + msg
+	}
+
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+
+	return msg
+}
+
+// Unwrap returns e.Cause.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Fail writes err as error response.
+// err is unwrapped to find *Error. *DecodeError is rendered with its status code.
+// Other errors are rendered as 500 Internal Server Error without their message.
+// Cause of 5xx error is logged by API.Logger instead, because it is not rendered.
+func (api *API) Fail(w http.ResponseWriter, err error) {
+	e := asError(err)
+
+	if e.HTTPStatus >= 500 && e.Cause != nil {
+		api.logCause(w, e)
+	}
+
+	if e.APIStatus != 0 {
+		api.APIStatus(w, e.APIStatus)
+	}
+
+	api.Error(w, e, e.HTTPStatus)
+}
+
+// logCause logs e.Cause with the request of w.
+func (api *API) logCause(w http.ResponseWriter, e *Error) {
+	var r *http.Request
+
+	if sw, ok := SafeWriterOf(w); ok {
+		r = sw.req
+	}
+
+	api.logger().Error("server error", append(requestAttrs(r), slog.Int("status", e.HTTPStatus), slog.Any("error", e.Cause))...)
+}
+
+// asError converts err to *Error that is safe to render.
+func asError(err error) *Error {
+	var (
+		e  *Error
+		de *DecodeError
+	)
+
+	switch {
+	case errors.As(err, &e):
+		if e.HTTPStatus == 0 || e.Message == "" {
+			c := *e
+
+			if c.HTTPStatus == 0 {
+				c.HTTPStatus = http.StatusInternalServerError
+			}
+
+			if c.Message == "" {
+				c.Message = http.StatusText(c.HTTPStatus)
+			}
+
+			e = &c
+		}
+
+		return e
+	case errors.As(err, &de):
+		return &Error{HTTPStatus: de.StatusCode, Message: http.StatusText(de.StatusCode), Cause: err}
+	default:
+		return &Error{HTTPStatus: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError), Cause: err}
+	}
+}
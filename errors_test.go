@@ -0,0 +1,153 @@
+package dou
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type apiStatusRecorder struct {
+	testAPI
+}
+
+func (p *apiStatusRecorder) APIStatus(w http.ResponseWriter, code int) {
+	w.Header().Set("X-API-Status", fmt.Sprint(code))
+}
+
+func (p *apiStatusRecorder) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func newTestFailAPI(err error) *API {
+	Register("teststatus", &apiStatusRecorder{})
+
+	defer Deregister("teststatus")
+
+	a, e := NewAPI("teststatus")
+
+	if e != nil {
+		panic(e)
+	}
+
+	a.Logger, _ = newTestLogger()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Fail(w, err)
+	})
+
+	return a
+}
+
+func TestFail(t *testing.T) {
+	cause := errors.New("secret internal error")
+
+	tests := []struct {
+		err               error
+		expectedCode      int
+		expectedAPIStatus string
+		expectedBody      map[string]interface{}
+	}{
+		{
+			&Error{HTTPStatus: 422, APIStatus: 100, Code: "validation_error", Message: "validation failed", Details: []FieldError{{Field: "name", Message: "name is required"}}, Cause: cause},
+			422,
+			"100",
+			map[string]interface{}{"code": "validation_error", "message": "validation failed", "details": []interface{}{map[string]interface{}{"field": "name", "message": "name is required"}}},
+		},
+		{
+			fmt.Errorf("wrapped: %w", NewError(http.StatusNotFound, "not_found", "")),
+			http.StatusNotFound,
+			"",
+			map[string]interface{}{"code": "not_found", "message": "Not Found"},
+		},
+		{
+			&Error{Message: "no status"},
+			http.StatusInternalServerError,
+			"",
+			map[string]interface{}{"message": "no status"},
+		},
+		{
+			&DecodeError{StatusCode: http.StatusUnsupportedMediaType, Err: ErrUnsupportedMediaType},
+			http.StatusUnsupportedMediaType,
+			"",
+			map[string]interface{}{"message": "Unsupported Media Type"},
+		},
+		{
+			cause,
+			http.StatusInternalServerError,
+			"",
+			map[string]interface{}{"message": "Internal Server Error"},
+		},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", "/", nil)
+		response := httptest.NewRecorder()
+
+		newTestFailAPI(test.err).ServeHTTP(response, request)
+
+		if response.Code != test.expectedCode {
+			t.Errorf("API.Fail(%v) should set http status code\nexpected: %v\ngot: %v\n", test.err, test.expectedCode, response.Code)
+		}
+
+		if got := response.Header().Get("X-API-Status"); got != test.expectedAPIStatus {
+			t.Errorf("API.Fail(%v) should set api status\nexpected: %v\ngot: %v\n", test.err, test.expectedAPIStatus, got)
+		}
+
+		var gotBody map[string]interface{}
+
+		if err := json.Unmarshal(response.Body.Bytes(), &gotBody); err != nil {
+			panic(err)
+		}
+
+		if !reflect.DeepEqual(gotBody, test.expectedBody) {
+			t.Errorf("API.Fail(%v) should render error\nexpected: %v\ngot: %v\n", test.err, test.expectedBody, gotBody)
+		}
+	}
+}
+
+func TestFailLogsCauseOfServerError(t *testing.T) {
+	cause := errors.New("secret internal error")
+
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{cause, 1},
+		{&Error{HTTPStatus: http.StatusBadGateway, Message: "upstream failed", Cause: cause}, 1},
+		{&Error{HTTPStatus: http.StatusBadGateway, Message: "upstream failed"}, 0},
+		{&Error{HTTPStatus: http.StatusUnprocessableEntity, Message: "validation failed", Cause: cause}, 0},
+	}
+
+	for _, test := range tests {
+		logger, buf := newTestLogger()
+
+		a := newTestFailAPI(test.err)
+		a.Logger = logger
+
+		request, _ := http.NewRequest("GET", "/fail", nil)
+		a.ServeHTTP(httptest.NewRecorder(), request)
+
+		records := decodeLogRecords(buf)
+
+		if len(records) != test.expected {
+			t.Errorf("API.Fail(%v) should log cause only for 5xx error\nexpected: %v\ngot: %v\n", test.err, test.expected, records)
+			continue
+		}
+
+		if test.expected > 0 && (records[0]["error"] != cause.Error() || records[0]["path"] != "/fail") {
+			t.Errorf("API.Fail(%v) should log cause with request attributes, but got %v", test.err, records[0])
+		}
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	err := &Error{HTTPStatus: http.StatusBadRequest, Message: "bad", Cause: cause}
+
+	if !errors.Is(err, cause) {
+		t.Error("Error should be unwrapped to Cause")
+	}
+}
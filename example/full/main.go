@@ -1,18 +1,11 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
 	"github.com/ToQoz/dou"
 	_ "github.com/ToQoz/dou/jsonapi"
-	"github.com/ToQoz/rome"
-	"github.com/lestrrat/go-apache-logformat"
 	"log"
-	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 )
 
@@ -23,33 +16,8 @@ var (
 	APIStatusValidationError = 100
 	// APIStatusUnexpectedError define unexpected error status code for X-API-Status
 	APIStatusUnexpectedError = 900
-	logger                   = apachelog.CombinedLog
 )
 
-// --- API Error type ---
-
-type apiError struct {
-	Message string `json:"message"`
-}
-
-func newAPIError(err error) *apiError {
-	return &apiError{Message: err.Error()}
-}
-
-type apiErrors struct {
-	Errors []*apiError `json:"errors"`
-}
-
-func newAPIErrors(errs []error) *apiErrors {
-	aErrs := &apiErrors{}
-
-	for _, err := range errs {
-		aErrs.Errors = append(aErrs.Errors, newAPIError(err))
-	}
-
-	return aErrs
-}
-
 // --- Example struct ---
 
 var users = []*User{}
@@ -61,15 +29,15 @@ type User struct {
 }
 
 // Validate validate fields.
-func (u *User) Validate() []error {
-	var errs []error
+func (u *User) Validate() []dou.FieldError {
+	var errs []dou.FieldError
 
 	if u.Name == "" {
-		errs = append(errs, errors.New("user: name is required"))
+		errs = append(errs, dou.FieldError{Field: "name", Code: "required", Message: "user: name is required"})
 	}
 
 	if u.Email == "" {
-		errs = append(errs, errors.New("user: email is required"))
+		errs = append(errs, dou.FieldError{Field: "email", Code: "required", Message: "user: email is required"})
 	}
 
 	return errs
@@ -82,76 +50,45 @@ func (u *User) Save() error {
 }
 
 func main() {
-	defer teardown()
-
-	// --- Setup Router ---
-	// ! You can use router keeping interface `api.Router` instead of github.com/ToQoz/rome
-	router := rome.NewRouter()
-	router.NotFoundFunc(func(w http.ResponseWriter, r *http.Request) {
-		j, err := json.Marshal(map[string]string{
-			"message":           http.StatusText(http.StatusNotFound),
-			"documentation_url": "http://toqoz.net",
-		})
-
-		if err != nil {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-			return
-		}
-
-		w.WriteHeader(http.StatusNotFound)
-		// skip wrote bytesyze
-		_, err = fmt.Fprintln(w, string(j))
-
-		if err != nil {
-			log.Printf("dou: fail to fmt.Fpintln(http.ResponseWriter, string)\n%v", err)
-		}
-	})
-
 	// --- Setup API ---
 	api, err := dou.NewAPI("jsonapi")
-	api.Handler = router
-	//api, err := dou.NewAPI("jsonapi")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	api.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
-		// Call default
-		w, r = api.Plugin.BeforeDispatch(w, r)
-
-		lw := apachelog.NewLoggingWriter(w, r, logger)
-		return lw, r
-	}
-
-	api.AfterDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
-		// Call default
-		w, r = api.Plugin.AfterDispatch(w, r)
+	// --- Setup Router ---
+	// ! You can use any http.Handler as api.Handler instead of dou.Router
+	// dou.Router renders 404 and 405 by api.Fail
+	router := dou.NewRouter(api)
+	api.Handler = router
 
-		if lw, ok := w.(*apachelog.LoggingWriter); ok {
-			lw.EmitLog()
-		}
+	// --- Request ID ---
+	api.RequestIDHeader = dou.DefaultRequestIDHeader
 
-		return w, r
-	}
+	// --- Access log ---
+	api.AccessLogger = dou.NewAccessLogger(os.Stdout, dou.CombinedLog)
 
 	api.ReadTimeout = 10 * time.Second
 	api.WriteTimeout = 10 * time.Second
 	api.MaxHeaderBytes = 1 << 20
 
 	// --- Map routes ---
-	router.GetFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+	router.Get("/users", func(w http.ResponseWriter, r *http.Request) {
 		api.APIStatus(w, APIStatusOk)
 		api.Ok(w, users, http.StatusOK)
 	})
 
-	router.GetFunc("/error", func(w http.ResponseWriter, r *http.Request) {
-		api.APIStatus(w, APIStatusUnexpectedError)
-		api.Error(w, map[string]string{"message": "Internal server error"}, http.StatusInternalServerError)
+	router.Get("/error", func(w http.ResponseWriter, r *http.Request) {
+		api.Fail(w, &dou.Error{
+			HTTPStatus: http.StatusInternalServerError,
+			APIStatus:  APIStatusUnexpectedError,
+			Message:    "Internal server error",
+		})
 	})
 
 	// Try Ok    $ curl -X POST -d 'name=ToQoz&email=toqoz403@gmail.com' -D - :8099/users
 	// Try Error $ curl -X POST -D - :8099/users
-	router.PostFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+	router.Post("/users", func(w http.ResponseWriter, r *http.Request) {
 		u := &User{
 			Name:  r.FormValue("name"),
 			Email: r.FormValue("email"),
@@ -160,16 +97,24 @@ func main() {
 		errs := u.Validate()
 
 		if len(errs) > 0 {
-			api.APIStatus(w, APIStatusValidationError)
-			api.Error(w, newAPIErrors(errs), 422)
+			api.Fail(w, &dou.Error{
+				HTTPStatus: 422,
+				APIStatus:  APIStatusValidationError,
+				Code:       "validation_error",
+				Message:    "Validation failed",
+				Details:    errs,
+			})
 			return
 		}
 
 		err := u.Save()
 
 		if err != nil {
-			api.APIStatus(w, APIStatusUnexpectedError)
-			api.Error(w, newAPIErrors(errs), http.StatusInternalServerError)
+			api.Fail(w, &dou.Error{
+				HTTPStatus: http.StatusInternalServerError,
+				APIStatus:  APIStatusUnexpectedError,
+				Cause:      err,
+			})
 			return
 		}
 
@@ -177,42 +122,26 @@ func main() {
 		api.Ok(w, u, http.StatusCreated)
 	})
 
+	// --- Teardown ---
+	api.AddTeardown(func() error {
+		log.Print("Tearing down...")
+		log.Print("Finished - bye bye.  ;-)")
+		return nil
+	})
+
 	// --- Create listener ---
-	// You can use utility, for example github.com/lestrrat/go-server-starter-listener etc.
-	l, err := net.Listen("tcp", ":8099")
+	// dou.Listen takes over listener inherited by systemd socket activation or api.Restart
+	l, err := dou.Listen("tcp", ":8099")
 
 	if err != nil {
-		log.Printf("Could not listen: %s", ":8099")
-		teardown()
-		os.Exit(1)
+		log.Fatalf("Could not listen: %s", ":8099")
 	}
 
 	log.Printf("Listen: %s", ":8099")
 
-	// --- Handle C-c ---
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-
-	go func() {
-		for sig := range c {
-			log.Print("Stopping the server...")
-
-			switch sig {
-			case os.Interrupt:
-				// --- Stop Server ---
-				api.Stop()
-				return
-			default:
-				log.Print("Receive unknown signal...")
-			}
-		}
-	}()
-
-	// --- Run Server ---
-	api.Run(l)
-}
+	// --- Restart without downtime by SIGHUP ---
+	api.RestartOnSignal()
 
-func teardown() {
-	log.Print("Tearing down...")
-	log.Print("Finished - bye bye.  ;-)")
+	// --- Run Server until C-c ---
+	os.Exit(api.RunUntilSignal(l))
 }
@@ -1,44 +1,16 @@
 package main
 
 import (
-	"errors"
 	"github.com/ToQoz/dou"
 	_ "github.com/ToQoz/dou/jsonapi"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 )
 
-// --- API Error type ---
-
-type apiError struct {
-	Message string `json:"message"`
-}
-
-func newAPIError(err error) *apiError {
-	return &apiError{Message: err.Error()}
-}
-
-type apiErrors struct {
-	Errors []*apiError `json:"errors"`
-}
-
-func newAPIErrors(errs []error) *apiErrors {
-	aErrs := &apiErrors{}
-
-	for _, err := range errs {
-		aErrs.Errors = append(aErrs.Errors, newAPIError(err))
-	}
-
-	return aErrs
-}
-
 func main() {
-	defer teardown()
-
 	api, err := dou.NewAPI("jsonapi")
 	if err != nil {
 		log.Fatal(err)
@@ -54,54 +26,35 @@ func main() {
 		case "/":
 			api.Ok(w, map[string]string{"hello": "world"}, http.StatusOK)
 		case "/error":
-			err := errors.New("some error occur")
-			api.Error(w, newAPIError(err), http.StatusInternalServerError)
+			api.Fail(w, &dou.Error{HTTPStatus: http.StatusInternalServerError, Message: "some error occur"})
 		case "/errors":
-			var errs []error
-			errs = append(errs, errors.New("1 error occur"))
-			errs = append(errs, errors.New("2 error occur"))
-			api.Error(w, newAPIErrors(errs), http.StatusInternalServerError)
+			api.Fail(w, &dou.Error{
+				HTTPStatus: http.StatusInternalServerError,
+				Message:    "some errors occur",
+				Details:    []dou.FieldError{{Message: "1 error occur"}, {Message: "2 error occur"}},
+			})
 		default:
-			api.Error(w, map[string]string{"message": http.StatusText(http.StatusNotFound)}, http.StatusNotFound)
+			api.Fail(w, dou.NewError(http.StatusNotFound, "not_found", ""))
 		}
 	})
 
+	// --- Teardown ---
+	api.AddTeardown(func() error {
+		log.Print("Tearing down...")
+		log.Print("Finished - bye bye.  ;-)")
+		return nil
+	})
+
 	// --- Create listener ---
 	// You can use utility, for example github.com/lestrrat/go-server-starter-listener etc.
 	l, err := net.Listen("tcp", ":8099")
 
 	if err != nil {
-		log.Printf("Could not listen: %s", ":8099")
-		teardown()
-		os.Exit(1)
+		log.Fatalf("Could not listen: %s", ":8099")
 	}
 
 	log.Printf("Listen: %s", ":8099")
 
-	// --- Handle C-c ---
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-
-	go func() {
-		for sig := range c {
-			log.Print("Stopping the server...")
-
-			switch sig {
-			case os.Interrupt:
-				// --- Stop Server ---
-				api.Stop()
-				return
-			default:
-				log.Print("Receive unknown signal...")
-			}
-		}
-	}()
-
-	// --- Run Server ---
-	api.Run(l)
-}
-
-func teardown() {
-	log.Print("Tearing down...")
-	log.Print("Finished - bye bye.  ;-)")
+	// --- Run Server until C-c ---
+	os.Exit(api.RunUntilSignal(l))
 }
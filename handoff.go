@@ -0,0 +1,266 @@
+package dou
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// ListenFDsEnv is environment variable of the number of inherited listeners.
+	// It is compatible with systemd socket activation.
+	ListenFDsEnv = "LISTEN_FDS"
+
+	// ListenPIDEnv is environment variable of pid that should inherit listeners.
+	// If this is empty, any process inherits them.
+	ListenPIDEnv = "LISTEN_PID"
+
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+
+	// listenFDsStart is the first file descriptor of inherited listeners.
+	listenFDsStart = 3
+)
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   []net.Listener // not taken by Listen yet
+	inheritErr  error
+)
+
+// InheritedListeners returns listeners inherited from parent process by API.Restart or systemd socket activation.
+// They are file descriptors from 3 to 3+LISTEN_FDS-1. LISTEN_* environment variables are unset after reading.
+// Listeners already taken by Listen are not contained.
+func InheritedListeners() ([]net.Listener, error) {
+	inheritOnce.Do(func() {
+		inherited, inheritErr = inheritListeners()
+	})
+
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+
+	return append([]net.Listener(nil), inherited...), inheritErr
+}
+
+// Listen returns inherited listener that listens on the same address, or announces on the local network address by net.Listen.
+// Use this to create listeners that are handed over by API.Restart.
+//
+//	l, err := dou.Listen("tcp", ":8099")
+func Listen(network, address string) (net.Listener, error) {
+	_, err := InheritedListeners()
+
+	if err != nil {
+		return nil, err
+	}
+
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+
+	for i, l := range inherited {
+		if sameAddr(l.Addr(), network, address) {
+			inherited = append(inherited[:i], inherited[i+1:]...)
+			return l, nil
+		}
+	}
+
+	return net.Listen(network, address)
+}
+
+// Restart starts new process of the same executable with the same arguments, hands listeners served by API.Run over to it,
+// and drains this process by API.Stop.
+// The new process should create listeners by Listen or InheritedListeners.
+// Unix socket files are kept when this process closes the listeners.
+func (api *API) Restart() error {
+	api.mu.Lock()
+	ls := api.listeners
+	running := api.server != nil && !api.shuttingDown
+	api.mu.Unlock()
+
+	if !running {
+		return ErrNotRunning
+	}
+
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+
+	defer func() {
+		for _, f := range files[3:] {
+			f.Close()
+		}
+	}()
+
+	for _, l := range ls {
+		f, err := listenerFile(l)
+
+		if err != nil {
+			return err
+		}
+
+		files = append(files, f)
+	}
+
+	exe, err := os.Executable()
+
+	if err != nil {
+		return err
+	}
+
+	var env []string
+
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, ListenFDsEnv+"=") && !strings.HasPrefix(kv, ListenPIDEnv+"=") && !strings.HasPrefix(kv, listenFDNamesEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	env = append(env, ListenFDsEnv+"="+strconv.Itoa(len(ls)))
+
+	p, err := os.StartProcess(exe, os.Args, &os.ProcAttr{Env: env, Files: files})
+
+	if err != nil {
+		return err
+	}
+
+	api.logger().Info("server restarting", slog.Int("pid", p.Pid))
+	p.Release()
+
+	for _, l := range ls {
+		keepSocketFile(l)
+	}
+
+	return api.Stop()
+}
+
+// RestartOnSignal calls API.Restart when one of signals is received. If no signal is given, syscall.SIGHUP is used.
+// Returned func stops watching signals.
+func (api *API) RestartOnSignal(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, signals...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				api.logger().Info("signal received. Restarting", slog.String("signal", sig.String()))
+
+				if err := api.Restart(); err != nil {
+					api.logger().Error("fail to restart", slog.Any("error", err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+func inheritListeners() ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv(ListenFDsEnv)
+		os.Unsetenv(ListenPIDEnv)
+		os.Unsetenv(listenFDNamesEnv)
+	}()
+
+	if pid := os.Getenv(ListenPIDEnv); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	fds := os.Getenv(ListenFDsEnv)
+
+	if fds == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("github.com/ToQoz/dou: invalid %s %q", ListenFDsEnv, fds)
+	}
+
+	var ls []net.Listener
+
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "listener"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+
+			return nil, fmt.Errorf("github.com/ToQoz/dou: fail to inherit listener from fd %d: %v", fd, err)
+		}
+
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
+
+// listenerFile returns duplicated file of l.
+func listenerFile(l net.Listener) (*os.File, error) {
+	fl, ok := l.(interface {
+		File() (*os.File, error)
+	})
+
+	if !ok {
+		return nil, fmt.Errorf("github.com/ToQoz/dou: listener %s can't be handed over", l.Addr())
+	}
+
+	return fl.File()
+}
+
+// keepSocketFile prevents closing l from removing its unix socket file, because the new process serves it.
+func keepSocketFile(l net.Listener) {
+	switch x := l.(type) {
+	case *net.UnixListener:
+		x.SetUnlinkOnClose(false)
+	case *tlsListener:
+		keepSocketFile(x.raw)
+	}
+}
+
+// sameAddr reports whether addr is the address that network and address mean.
+func sameAddr(addr net.Addr, network, address string) bool {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		if !strings.HasPrefix(network, "tcp") {
+			return false
+		}
+
+		ta, err := net.ResolveTCPAddr(network, address)
+
+		if err != nil || ta.Port != a.Port {
+			return false
+		}
+
+		if ta.IP == nil || ta.IP.IsUnspecified() {
+			return a.IP == nil || a.IP.IsUnspecified()
+		}
+
+		return ta.IP.Equal(a.IP)
+	case *net.UnixAddr:
+		return network == a.Net && address == a.Name
+	default:
+		return false
+	}
+}
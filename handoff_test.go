@@ -0,0 +1,198 @@
+package dou
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHandoffHelperProcess is not a real test. It serves as the process that inherits listener.
+func TestHandoffHelperProcess(t *testing.T) {
+	if os.Getenv("DOU_TEST_HANDOFF_ADDR") == "" {
+		return
+	}
+
+	network := os.Getenv("DOU_TEST_HANDOFF_NETWORK")
+
+	if network == "" {
+		network = "tcp"
+	}
+
+	l, err := Listen(network, os.Getenv("DOU_TEST_HANDOFF_ADDR"))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if ls, _ := InheritedListeners(); len(ls) != 0 {
+		fmt.Fprintln(os.Stderr, "Listen should take inherited listener")
+		os.Exit(1)
+	}
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("child"))
+		go a.Stop()
+	})
+
+	time.AfterFunc(10*time.Second, func() { os.Exit(1) })
+
+	a.Run(l)
+	os.Exit(0)
+}
+
+func helperCommand(t *testing.T, addr string, l net.Listener) *exec.Cmd {
+	f, err := listenerFile(l)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandoffHelperProcess$")
+	cmd.Env = append(os.Environ(), "DOU_TEST_HANDOFF_ADDR="+addr, ListenFDsEnv+"=1")
+	cmd.ExtraFiles = []*os.File{f}
+
+	return cmd
+}
+
+func getBody(t *testing.T, network, addr string) string {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+		Timeout: 10 * time.Second,
+	}
+
+	res, err := client.Get("http://dou/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	b, _ := ioutil.ReadAll(res.Body)
+	return string(b)
+}
+
+func TestListenInheritsListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	addr := l.Addr().String()
+	cmd := helperCommand(t, addr, l)
+	l.Close()
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := getBody(t, "tcp", addr); body != "child" {
+		t.Errorf("inherited listener should be served by child process\nexpected: %v\ngot: %v\n", "child", body)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("child process should exit successfully, but got %v", err)
+	}
+}
+
+func TestRestartHandsListenersOver(t *testing.T) {
+	for _, test := range []struct {
+		network string
+		address string
+	}{
+		{"tcp", "127.0.0.1:0"},
+		{"unix", filepath.Join(t.TempDir(), "dou.sock")},
+	} {
+		l, err := net.Listen(test.network, test.address)
+
+		if err != nil {
+			panic(err)
+		}
+
+		addr := l.Addr().String()
+
+		a := newTestAPI()
+		a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("parent"))
+		})
+
+		runReturned := make(chan error, 1)
+		go func() {
+			runReturned <- a.Run(l)
+		}()
+
+		if body := getBody(t, test.network, addr); body != "parent" {
+			t.Errorf("%s: parent should serve before restart\nexpected: %v\ngot: %v\n", test.network, "parent", body)
+		}
+
+		t.Setenv("DOU_TEST_HANDOFF_NETWORK", test.network)
+		t.Setenv("DOU_TEST_HANDOFF_ADDR", addr)
+
+		args, stdout := os.Args, os.Stdout
+		os.Args = []string{os.Args[0], "-test.run=^TestHandoffHelperProcess$"}
+		os.Stdout, _ = os.Open(os.DevNull)
+
+		err = a.Restart()
+
+		os.Stdout.Close()
+		os.Args, os.Stdout = args, stdout
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := <-runReturned; err != ErrServerStopped {
+			t.Errorf("%s: API.Run should return ErrServerStopped after restart\nexpected: %v\ngot: %v\n", test.network, ErrServerStopped, err)
+		}
+
+		// Closing the parent's listener should not remove unix socket file.
+		if body := getBody(t, test.network, addr); body != "child" {
+			t.Errorf("%s: new process should serve after restart\nexpected: %v\ngot: %v\n", test.network, "child", body)
+		}
+	}
+}
+
+func TestRestartReturnsErrNotRunning(t *testing.T) {
+	a := newTestAPI()
+
+	if err := a.Restart(); err != ErrNotRunning {
+		t.Errorf("API.Restart should return ErrNotRunning before Run\nexpected: %v\ngot: %v\n", ErrNotRunning, err)
+	}
+}
+
+func TestSameAddr(t *testing.T) {
+	tests := []struct {
+		addr     net.Addr
+		network  string
+		address  string
+		expected bool
+	}{
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8099}, "tcp", ":8099", true},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8099}, "tcp", "127.0.0.1:8099", true},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8099}, "tcp", ":8099", false},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8099}, "tcp", ":8080", false},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8099}, "unix", ":8099", false},
+		{&net.UnixAddr{Net: "unix", Name: "/tmp/dou.sock"}, "unix", "/tmp/dou.sock", true},
+	}
+
+	for _, test := range tests {
+		if got := sameAddr(test.addr, test.network, test.address); got != test.expected {
+			t.Errorf("sameAddr(%v, %q, %q)\nexpected: %v\ngot: %v\n", test.addr, test.network, test.address, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package dou
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health statuses in HealthReport.
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthReport is response body of LivenessHandler and ReadinessHandler.
+// It is rendered by API.Ok, so it is marshaled by plugin even if the status is 503.
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is result of a readiness check.
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type readinessCheck struct {
+	name    string
+	timeout time.Duration
+	check   func(ctx context.Context) error
+}
+
+// AddReadinessCheck registers readiness check.
+// check should return nil when the dependency is ready. It is canceled after timeout if timeout is positive.
+// Checks are run concurrently for each request to ReadinessHandler.
+func (api *API) AddReadinessCheck(name string, timeout time.Duration, check func(ctx context.Context) error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.readinessChecks = append(api.readinessChecks, readinessCheck{name: name, timeout: timeout, check: check})
+}
+
+// LivenessHandler returns handler that always responds 200 while the process can serve requests.
+func (api *API) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		api.Ok(w, &HealthReport{Status: HealthOK}, http.StatusOK)
+	})
+}
+
+// ReadinessHandler returns handler that responds 200 when API is ready to receive traffic.
+// It responds 503 Service Unavailable while API is not running (before API.Run starts or after it returned),
+// as soon as API.Shutdown or API.Stop begins, and while any readiness check registered by AddReadinessCheck fails.
+// So load balancers can stop sending traffic before the listener is closed.
+func (api *API) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := api.checkReadiness(r.Context())
+
+		status := http.StatusOK
+
+		if report.Status != HealthOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		api.Ok(w, report, status)
+	})
+}
+
+// Ready reports whether API is ready to receive traffic. It runs readiness checks.
+func (api *API) Ready(ctx context.Context) bool {
+	return api.checkReadiness(ctx).Status == HealthOK
+}
+
+func (api *API) checkReadiness(ctx context.Context) *HealthReport {
+	api.mu.Lock()
+	serving := api.server != nil && !api.shuttingDown
+	checks := append([]readinessCheck(nil), api.readinessChecks...)
+	api.mu.Unlock()
+
+	report := &HealthReport{Status: HealthOK}
+
+	if !serving {
+		report.Status = HealthUnavailable
+	}
+
+	if len(checks) == 0 {
+		return report
+	}
+
+	report.Checks = make(map[string]*CheckResult, len(checks))
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	for _, c := range checks {
+		wg.Add(1)
+
+		go func(c readinessCheck) {
+			defer wg.Done()
+
+			err := runCheck(ctx, c)
+			result := &CheckResult{Status: HealthOK}
+
+			if err != nil {
+				result.Status = HealthUnavailable
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[c.name] = result
+
+			if err != nil {
+				report.Status = HealthUnavailable
+			}
+		}(c)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// runCheck runs c with its timeout.
+// It returns ctx.Err() if check doesn't return before timeout.
+func runCheck(ctx context.Context, c readinessCheck) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
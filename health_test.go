@@ -0,0 +1,185 @@
+package dou
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestHealthAPI() *API {
+	a := newTestAPI()
+
+	testAPIMarshal = func(v interface{}) ([]byte, error) {
+		return json.Marshal(v)
+	}
+
+	return a
+}
+
+func serveHealth(h http.Handler) (int, *HealthReport) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	h.ServeHTTP(response, request)
+
+	report := &HealthReport{}
+
+	if err := json.Unmarshal(response.Body.Bytes(), report); err != nil {
+		panic(err)
+	}
+
+	return response.Code, report
+}
+
+// runHealthAPI runs a until it becomes ready, and returns func that stops it.
+func runHealthAPI(a *API) (stop func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	for i := 0; i < 50 && !a.Ready(context.Background()); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	return func() {
+		a.Stop()
+		<-runReturned
+	}
+}
+
+func TestLivenessHandler(t *testing.T) {
+	a := newTestHealthAPI()
+
+	code, report := serveHealth(a.LivenessHandler())
+
+	if code != http.StatusOK || report.Status != HealthOK {
+		t.Errorf("LivenessHandler should respond ok, but got %v %v", code, report.Status)
+	}
+}
+
+func TestReadinessHandlerRunsChecks(t *testing.T) {
+	a := newTestHealthAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	a.AddReadinessCheck("db", time.Second, func(ctx context.Context) error {
+		return nil
+	})
+
+	stop := runHealthAPI(a)
+	defer stop()
+
+	code, report := serveHealth(a.ReadinessHandler())
+
+	if code != http.StatusOK || report.Status != HealthOK {
+		t.Errorf("ReadinessHandler should respond ok if checks pass, but got %v %v", code, report.Status)
+	}
+
+	a.AddReadinessCheck("cache", time.Second, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	a.AddReadinessCheck("slow", 10*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	code, report = serveHealth(a.ReadinessHandler())
+
+	if code != http.StatusServiceUnavailable || report.Status != HealthUnavailable {
+		t.Errorf("ReadinessHandler should respond 503 if a check fails, but got %v %v", code, report.Status)
+	}
+
+	expected := map[string]*CheckResult{
+		"db":    {Status: HealthOK},
+		"cache": {Status: HealthUnavailable, Error: "connection refused"},
+		"slow":  {Status: HealthUnavailable, Error: context.DeadlineExceeded.Error()},
+	}
+
+	if !reflect.DeepEqual(report.Checks, expected) {
+		t.Errorf("ReadinessHandler should render check results\nexpected: %v\ngot: %v\n", expected, report.Checks)
+	}
+}
+
+func TestReadinessFailsWhenNotRunning(t *testing.T) {
+	a := newTestHealthAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	if code, report := serveHealth(a.ReadinessHandler()); code != http.StatusServiceUnavailable || report.Status != HealthUnavailable {
+		t.Errorf("ReadinessHandler should respond 503 before API.Run starts, but got %v %v", code, report.Status)
+	}
+
+	stop := runHealthAPI(a)
+
+	if !a.Ready(context.Background()) {
+		t.Error("API should be ready while running")
+	}
+
+	stop()
+
+	if code, report := serveHealth(a.ReadinessHandler()); code != http.StatusServiceUnavailable || report.Status != HealthUnavailable {
+		t.Errorf("ReadinessHandler should respond 503 after API.Run returned, but got %v %v", code, report.Status)
+	}
+}
+
+func TestReadinessFailsWhenShutdownBegins(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a := newTestHealthAPI()
+	a.ShutdownDelay = 100 * time.Millisecond
+	a.Handler = a.ReadinessHandler()
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	url := "http://" + l.Addr().String() + "/"
+	res, err := http.Get(url)
+
+	if err != nil {
+		panic(err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("readiness should be ok while running, but got %v", res.StatusCode)
+	}
+
+	go a.Shutdown(context.Background())
+
+	for i := 0; i < 50 && a.Ready(context.Background()); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Listener is still open during ShutdownDelay.
+	res, err = http.Get(url)
+
+	if err != nil {
+		t.Fatalf("listener should not be closed during ShutdownDelay, but got %v", err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("readiness should fail as soon as shutdown begins, but got %v", res.StatusCode)
+	}
+
+	<-runReturned
+}
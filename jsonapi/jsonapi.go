@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ToQoz/dou"
-	"log"
 	"net/http"
 	"strconv"
 )
@@ -29,20 +28,27 @@ func (ja *jsonAPI) AfterDispatch(w http.ResponseWriter, r *http.Request) (http.R
 
 // OnPanic is called when panic occur.
 func (ja *jsonAPI) OnPanic(w http.ResponseWriter, r *http.Request) {
-	// if api.SafeWriter.Write called before occuring panic,
+	// if api.SafeWriter.Write or WriteHeader called before occuring panic,
 	// this will not write response body and header.
 	// Because it is meaningless and foolish that jsonplugin.OnPanic break response body.
 	// Example: Write([]byte("{}") -> some proccess -> panic -> jsonplugin.OnPanic -> Write([]byte(`{"message": "Internal Server Error"}`))
 	//          -> Response body is {}{"message": "Internal Server Error"}.
-	if sw, ok := w.(*dou.SafeWriter); ok {
-		if sw.Wrote {
+	if sw, ok := dou.SafeWriterOf(w); ok {
+		if sw.Wrote || sw.WroteHeader {
 			return
 		}
 	}
 
 	var b string
 
-	j, err := json.Marshal(map[string]string{"message": http.StatusText(http.StatusInternalServerError)})
+	body := map[string]string{"message": http.StatusText(http.StatusInternalServerError)}
+
+	// Return request id for correlating client reports with logs.
+	if rc := dou.FromRequest(r); rc != nil && rc.RequestID != "" {
+		body["request_id"] = rc.RequestID
+	}
+
+	j, err := json.Marshal(body)
 
 	if err != nil {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -58,10 +64,15 @@ func (ja *jsonAPI) OnPanic(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// Skip error
 		// http.Error skip this error too.
-		log.Printf("dou: fail to fmt.Fpintln(http.ResponseWriter, string)\n%v", err)
+		dou.ReportWriteError(r, err)
 	}
 }
 
+// MediaTypes returns media types for content negotiation.
+func (ja *jsonAPI) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
 // Marshal a interface to a JSON.
 func (ja *jsonAPI) Marshal(v interface{}) ([]byte, error) {
 	return json.Marshal(v)
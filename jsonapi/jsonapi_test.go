@@ -3,6 +3,7 @@ package jsonapi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/ToQoz/dou"
 	"net/http"
 	"net/http/httptest"
@@ -171,3 +172,92 @@ func TestAPIStatus(t *testing.T) {
 		t.Errorf("APIStatus should set X-API-Status. (expect) = \"999\", but (got) = %v", response.Header().Get("X-API-Status"))
 	}
 }
+
+// OnPanic should not write if header is already written before panic occur
+func TestOnPanicDontWriteIfHeaderIsAlreadyWrittenBeforePanicOccur(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a, err := dou.NewAPI("jsonapi")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a.LogStackTrace = false
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("<test panic>")
+	})
+
+	a.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted || response.Body.Len() != 0 {
+		t.Errorf("OnPanic should not write response if header is written before panic occur. (got) = %v %s", response.Code, response.Body.Bytes())
+	}
+}
+
+// OnPanic should write request id
+func TestOnPanicWriteRequestID(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("X-Request-ID", "req-1")
+	response := httptest.NewRecorder()
+
+	a, err := dou.NewAPI("jsonapi")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a.LogStackTrace = false
+	a.RequestIDHeader = dou.DefaultRequestIDHeader
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("<test panic>")
+	})
+
+	a.ServeHTTP(response, request)
+
+	gotJSON := map[string]string{}
+
+	if err := json.Unmarshal(response.Body.Bytes(), &gotJSON); err != nil {
+		panic(err)
+	}
+
+	if gotJSON["request_id"] != "req-1" {
+		t.Errorf("OnPanic should write request id. (got) = %s", response.Body.Bytes())
+	}
+}
+
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+var errBrokenWriter = errors.New("broken writer")
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	return 0, errBrokenWriter
+}
+
+func TestOnPanicReportsWriteError(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := &brokenWriter{httptest.NewRecorder()}
+
+	reporter := &dou.MemoryReporter{}
+
+	a, err := dou.NewAPI("jsonapi")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a.Reporter = reporter
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("<handler panic>")
+	})
+
+	a.ServeHTTP(response, request)
+
+	if writeErrors := reporter.WriteErrors(); len(writeErrors) != 1 || writeErrors[0].Err != errBrokenWriter {
+		t.Errorf("OnPanic should report write error to Reporter, but got %v", writeErrors)
+	}
+}
@@ -0,0 +1,90 @@
+package dou
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+)
+
+// OnStart registers f that is called with bound address of each listener when API.Run starts.
+// They are called in registered order before serving. e.g. registering with service discovery, warming caches.
+// If f returns error, API.Run returns it without serving.
+func (api *API) OnStart(f func(addr net.Addr) error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.startHooks = append(api.startHooks, f)
+}
+
+// OnStop registers f that is called once after the server stopped serving by API.Shutdown, API.Stop or failure of a listener.
+// They are called in registered order after in-flight requests are drained, and API.Run returns after they finished.
+// ctx carries values of ctx given to API.Shutdown, but it is not canceled with it even if draining used up its deadline.
+// Instead, it has own deadline of API.ShutdownTimeout, or DefaultDrainTimeout if it is zero. e.g. flushing buffers.
+func (api *API) OnStop(f func(ctx context.Context) error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.stopHooks = append(api.stopHooks, f)
+}
+
+// OnShutdownTimeout registers f that is called when in-flight requests are not drained before deadline of API.Shutdown.
+// They are called before remaining connections are closed.
+func (api *API) OnShutdownTimeout(f func()) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.shutdownTimeoutHooks = append(api.shutdownTimeoutHooks, f)
+}
+
+func (api *API) runStartHooks(addr net.Addr) error {
+	api.mu.Lock()
+	hooks := api.startHooks
+	api.mu.Unlock()
+
+	for _, f := range hooks {
+		if err := f(addr); err != nil {
+			api.logger().Error("start hook failed", slog.String("addr", addr.String()), slog.Any("error", err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (api *API) runStopHooks(ctx context.Context) error {
+	api.mu.Lock()
+	hooks := api.stopHooks
+	api.mu.Unlock()
+
+	timeout := api.ShutdownTimeout
+
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+
+	// ctx may be already done by draining in-flight requests.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	var errs []error
+
+	for _, f := range hooks {
+		if err := f(ctx); err != nil {
+			api.logger().Error("stop hook failed", slog.Any("error", err))
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (api *API) runShutdownTimeoutHooks() {
+	api.mu.Lock()
+	hooks := api.shutdownTimeoutHooks
+	api.mu.Unlock()
+
+	for _, f := range hooks {
+		f()
+	}
+}
@@ -0,0 +1,162 @@
+package dou
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var (
+		events  []string
+		started = make(chan net.Addr, 1)
+	)
+
+	a.OnStart(func(addr net.Addr) error {
+		events = append(events, "start")
+		started <- addr
+		return nil
+	})
+
+	a.OnStop(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("stop hook should receive context with deadline even if API.ShutdownTimeout is zero")
+		}
+
+		events = append(events, "stop1")
+		return nil
+	})
+
+	a.OnStop(func(ctx context.Context) error {
+		events = append(events, "stop2")
+		return nil
+	})
+
+	a.OnShutdownTimeout(func() {
+		events = append(events, "timeout")
+	})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	if addr := <-started; addr.String() != l.Addr().String() {
+		t.Errorf("start hook should receive bound address\nexpected: %v\ngot: %v\n", l.Addr(), addr)
+	}
+
+	for a.Stop() == ErrNotRunning {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := <-runReturned; err != ErrServerStopped {
+		t.Errorf("API.Run should return ErrServerStopped\nexpected: %v\ngot: %v\n", ErrServerStopped, err)
+	}
+
+	if expected := []string{"start", "stop1", "stop2"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("lifecycle hooks should be called in order before API.Run returns\nexpected: %v\ngot: %v\n", expected, events)
+	}
+}
+
+func TestRunReturnsStartHookError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer l.Close()
+
+	errRegister := errors.New("fail to register")
+
+	a := newTestAPI()
+	a.Logger, _ = newTestLogger()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	a.OnStart(func(addr net.Addr) error {
+		return errRegister
+	})
+
+	if err := a.Run(l); err != errRegister {
+		t.Errorf("API.Run should return error of start hook\nexpected: %v\ngot: %v\n", errRegister, err)
+	}
+}
+
+func TestShutdownTimeoutHooks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	errFlush := errors.New("fail to flush")
+
+	a := newTestAPI()
+	a.Logger, _ = newTestLogger()
+	a.ShutdownTimeout = 20 * time.Millisecond
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	timedOut := false
+
+	a.OnShutdownTimeout(func() {
+		timedOut = true
+	})
+
+	a.OnStop(func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			t.Errorf("stop hook should receive context that is not done yet, but got %v", ctx.Err())
+		}
+
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("stop hook should receive context with deadline")
+		}
+
+		return errFlush
+	})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/")
+
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	<-entered
+
+	err = a.Stop()
+
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errFlush) {
+		t.Errorf("API.Stop should return deadline error joined with stop hook error, but got %v", err)
+	}
+
+	if !timedOut {
+		t.Error("shutdown timeout hook should be called if in-flight requests are not drained")
+	}
+
+	<-runReturned
+}
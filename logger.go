@@ -0,0 +1,43 @@
+package dou
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// logger returns api.Logger or slog.Default().
+func (api *API) logger() *slog.Logger {
+	if api.Logger != nil {
+		return api.Logger
+	}
+
+	return slog.Default()
+}
+
+// LoggerFor returns logger of API serving r with request attributes (method, path and request_id).
+// If r is nil or not served by API, slog.Default() is used.
+// Plugins should log through this instead of package log.
+func LoggerFor(r *http.Request) *slog.Logger {
+	logger := slog.Default()
+
+	if rc := FromRequest(r); rc != nil && rc.Logger != nil {
+		logger = rc.Logger
+	}
+
+	return logger.With(requestAttrs(r)...)
+}
+
+// requestAttrs returns attributes that describe r. r may be nil.
+func requestAttrs(r *http.Request) []interface{} {
+	if r == nil {
+		return nil
+	}
+
+	attrs := []interface{}{slog.String("method", requestMethod(r)), slog.String("path", requestPath(r))}
+
+	if rc := FromRequest(r); rc != nil && rc.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", rc.RequestID))
+	}
+
+	return attrs
+}
@@ -0,0 +1,163 @@
+package dou
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	return slog.New(slog.NewJSONHandler(buf, nil)), buf
+}
+
+func decodeLogRecords(buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+
+	dec := json.NewDecoder(buf)
+
+	for dec.More() {
+		record := map[string]interface{}{}
+
+		if err := dec.Decode(&record); err != nil {
+			panic(err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestLoggerRecordsPanic(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/panic", nil)
+	response := httptest.NewRecorder()
+
+	logger, buf := newTestLogger()
+
+	a := newTestAPI()
+	a.Logger = logger
+	a.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		FromRequest(r).RequestID = "req-1"
+		return w, r
+	}
+	a.Handler = http.HandlerFunc(panicInHandler)
+
+	a.ServeHTTP(response, request)
+
+	records := decodeLogRecords(buf)
+
+	if len(records) != 1 {
+		t.Fatalf("API should log panic once, but got %v", records)
+	}
+
+	expected := map[string]interface{}{
+		"level":      "ERROR",
+		"method":     "GET",
+		"path":       "/panic",
+		"request_id": "req-1",
+		"phase":      "Handler",
+		"panic":      "<handler panic>",
+	}
+
+	for k, v := range expected {
+		if records[0][k] != v {
+			t.Errorf("panic record should have %s\nexpected: %v\ngot: %v\n", k, v, records[0][k])
+		}
+	}
+
+	if records[0]["stack"] == "" {
+		t.Error("panic record should have stack")
+	}
+}
+
+func TestLoggerRecordsWriteError(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/write", nil)
+	response := &brokenWriter{httptest.NewRecorder()}
+
+	logger, buf := newTestLogger()
+
+	a := newTestAPI()
+	a.Logger = logger
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Ok(w, "", http.StatusOK)
+	})
+
+	a.ServeHTTP(response, request)
+
+	records := decodeLogRecords(buf)
+
+	if len(records) != 1 || records[0]["error"] != errBrokenWriter.Error() || records[0]["path"] != "/write" {
+		t.Errorf("API should log write error with request attributes, but got %v", records)
+	}
+}
+
+func TestLoggerRecordsServerStartAndStop(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	logger, buf := newTestLogger()
+
+	a := newTestAPI()
+	a.Logger = logger
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.Run(l)
+	}()
+
+	// Wait until server is started.
+	res, err := http.Get("http://" + l.Addr().String() + "/")
+
+	if err != nil {
+		panic(err)
+	}
+
+	res.Body.Close()
+
+	a.Shutdown(context.Background())
+	<-runReturned
+
+	var messages []interface{}
+
+	for _, record := range decodeLogRecords(buf) {
+		messages = append(messages, record["msg"])
+
+		if record["msg"] == "server started" && record["addr"] != l.Addr().String() {
+			t.Errorf("server started record should have addr\nexpected: %v\ngot: %v\n", l.Addr().String(), record["addr"])
+		}
+	}
+
+	expected := []interface{}{"server started", "server shutting down", "server stopped"}
+
+	if len(messages) != len(expected) {
+		t.Fatalf("API should log server start and stop\nexpected: %v\ngot: %v\n", expected, messages)
+	}
+
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("API should log server start and stop\nexpected: %v\ngot: %v\n", expected, messages)
+		}
+	}
+}
+
+func TestLoggerForOutsideAPI(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+
+	if LoggerFor(request) == nil {
+		t.Error("LoggerFor should return default logger if request is not served by API")
+	}
+
+	if LoggerFor(nil) == nil {
+		t.Error("LoggerFor should return default logger if request is nil")
+	}
+}
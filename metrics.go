@@ -0,0 +1,247 @@
+package dou
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are default buckets of request duration histogram in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects traffic of API, and exposes them in Prometheus text exposition format by ServeHTTP.
+// Set this to API.Metrics and mount it to any path.
+//
+//	api.Metrics = dou.NewMetrics()
+//	router.Handle("GET", "/metrics", api.Metrics)
+//
+// Route label is pattern matched by Router. It is empty if the request isn't dispatched by Router.
+type Metrics struct {
+	buckets []float64
+
+	mu          sync.Mutex
+	inFlight    int64
+	requests    map[requestLabels]uint64
+	durations   map[durationLabels]*histogram
+	panics      map[Phase]uint64
+	apiStatuses map[int]uint64
+}
+
+type requestLabels struct {
+	method string
+	route  string
+	status int
+}
+
+type durationLabels struct {
+	method string
+	route  string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics new Metrics with buckets of request duration histogram.
+// If buckets is empty, DefaultBuckets is used.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:     buckets,
+		requests:    map[requestLabels]uint64{},
+		durations:   map[durationLabels]*histogram{},
+		panics:      map[Phase]uint64{},
+		apiStatuses: map[int]uint64{},
+	}
+}
+
+// begin is called when API starts serving a request.
+func (m *Metrics) begin() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight++
+}
+
+// end is called when API finished serving a request.
+func (m *Metrics) end(sw *SafeWriter, rc *RequestContext) {
+	method := metricMethod(sw.req)
+	status := sw.Status
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	elapsed := time.Since(rc.Start).Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight--
+	m.requests[requestLabels{method: method, route: rc.Route, status: status}]++
+
+	dl := durationLabels{method: method, route: rc.Route}
+	h, ok := m.durations[dl]
+
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[dl] = h
+	}
+
+	for i, le := range m.buckets {
+		if elapsed <= le {
+			h.counts[i]++
+			break
+		}
+	}
+
+	h.sum += elapsed
+	h.count++
+
+	if rc.Panic != nil {
+		m.panics[rc.Panic.Phase]++
+	}
+
+	if rc.APIStatus != 0 {
+		m.apiStatuses[rc.APIStatus]++
+	}
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes metrics in Prometheus text exposition format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := &strings.Builder{}
+
+	writeHeader(b, "dou_http_requests_in_flight", "gauge", "Number of HTTP requests being served.")
+	fmt.Fprintf(b, "dou_http_requests_in_flight %d\n", m.inFlight)
+
+	writeHeader(b, "dou_http_requests_total", "counter", "Total number of HTTP requests.")
+	requests := make([]requestLabels, 0, len(m.requests))
+
+	for l := range m.requests {
+		requests = append(requests, l)
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+
+		if a.route != b.route {
+			return a.route < b.route
+		}
+
+		if a.method != b.method {
+			return a.method < b.method
+		}
+
+		return a.status < b.status
+	})
+
+	for _, l := range requests {
+		fmt.Fprintf(b, "dou_http_requests_total{method=%s,route=%s,status=\"%d\"} %d\n", labelValue(l.method), labelValue(l.route), l.status, m.requests[l])
+	}
+
+	writeHeader(b, "dou_http_request_duration_seconds", "histogram", "Duration of HTTP requests in seconds.")
+	durations := make([]durationLabels, 0, len(m.durations))
+
+	for l := range m.durations {
+		durations = append(durations, l)
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		a, b := durations[i], durations[j]
+
+		if a.route != b.route {
+			return a.route < b.route
+		}
+
+		return a.method < b.method
+	})
+
+	for _, l := range durations {
+		h := m.durations[l]
+		labels := fmt.Sprintf("method=%s,route=%s", labelValue(l.method), labelValue(l.route))
+
+		var cumulative uint64
+
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "dou_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+
+		fmt.Fprintf(b, "dou_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(b, "dou_http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "dou_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	writeHeader(b, "dou_http_panics_total", "counter", "Total number of panics recovered in API.ServeHTTP.")
+	phases := make([]Phase, 0, len(m.panics))
+
+	for p := range m.panics {
+		phases = append(phases, p)
+	}
+
+	sort.Slice(phases, func(i, j int) bool { return phases[i] < phases[j] })
+
+	for _, p := range phases {
+		fmt.Fprintf(b, "dou_http_panics_total{phase=%s} %d\n", labelValue(p.String()), m.panics[p])
+	}
+
+	writeHeader(b, "dou_api_status_total", "counter", "Total number of responses by API status. e.g. X-API-Status")
+	codes := make([]int, 0, len(m.apiStatuses))
+
+	for c := range m.apiStatuses {
+		codes = append(codes, c)
+	}
+
+	sort.Ints(codes)
+
+	for _, c := range codes {
+		fmt.Fprintf(b, "dou_api_status_total{api_status=\"%d\"} %d\n", c, m.apiStatuses[c])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func writeHeader(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// labelValue quotes label value for Prometheus text format.
+func labelValue(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// metricMethod returns method label.
+// Unknown methods are aggregated to "OTHER" for preventing high cardinality.
+func metricMethod(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return r.Method
+	default:
+		return "OTHER"
+	}
+}
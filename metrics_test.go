@@ -0,0 +1,81 @@
+package dou
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	a, router := newTestRouterAPI()
+	a.LogStackTrace = false
+	a.Metrics = NewMetrics(0.1, 1)
+
+	router.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		a.APIStatus(w, 1)
+		a.Ok(w, "", http.StatusOK)
+	})
+
+	router.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		panic("<test panic>")
+	})
+
+	router.Handle("GET", "/metrics", a.Metrics)
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"POST", "/users"},
+		{"GET", "/unknown"},
+		{"BREW", "/users/1"},
+	} {
+		request, _ := http.NewRequest(req.method, req.path, nil)
+		a.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/metrics")
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	body := string(b)
+
+	if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Metrics should be served in Prometheus text format, but Content-Type is %v", got)
+	}
+
+	for _, expected := range []string{
+		"# TYPE dou_http_requests_total counter\n",
+		`dou_http_requests_total{method="GET",route="/users/:id",status="200"} 2` + "\n",
+		`dou_http_requests_total{method="POST",route="/users",status="200"} 1` + "\n",
+		`dou_http_requests_total{method="GET",route="",status="404"} 1` + "\n",
+		`dou_http_requests_total{method="OTHER",route="",status="405"} 1` + "\n",
+		"# TYPE dou_http_request_duration_seconds histogram\n",
+		`dou_http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="0.1"} 2` + "\n",
+		`dou_http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="1"} 2` + "\n",
+		`dou_http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="+Inf"} 2` + "\n",
+		`dou_http_request_duration_seconds_count{method="GET",route="/users/:id"} 2` + "\n",
+		// the scraping request itself
+		"dou_http_requests_in_flight 1\n",
+		`dou_http_panics_total{phase="Handler"} 1` + "\n",
+		`dou_api_status_total{api_status="1"} 2` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Metrics should contain %q\ngot:\n%s", expected, body)
+		}
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got, expected := labelValue("a\"b\\c\nd"), `"a\"b\\c\nd"`; got != expected {
+		t.Errorf("labelValue should escape value\nexpected: %v\ngot: %v\n", expected, got)
+	}
+}
@@ -0,0 +1,64 @@
+package dou
+
+import (
+	"net/http"
+)
+
+// errUseAfterServing is panicked by API.Use after the middleware chain is built.
+const errUseAfterServing = "github.com/ToQoz/dou: Use called after API started serving"
+
+// Use appends middlewares that wrap API.Handler.
+// Middlewares are called after API.BeforeDispatch and before API.AfterDispatch in order of registration.
+// So the first one is the outermost and receives http.ResponseWriter returned by API.BeforeDispatch.
+//
+//	BeforeDispatch -> mw[0] -> mw[1] -> ... -> Handler -> AfterDispatch
+//
+// A middleware can short-circuit the request by not calling next.
+// Panic in a middleware is recovered as same as panic in API.Handler.
+// OnPanic is called, and then AfterDispatch is called.
+//
+// The chain is built once on the first request. So Use should be called before serving, and it panics after that.
+// API.Handler is read on each request as before.
+func (api *API) Use(mw ...func(http.Handler) http.Handler) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if api.chain != nil {
+		panic(errUseAfterServing)
+	}
+
+	api.middlewares = append(api.middlewares, mw...)
+}
+
+// handler returns API.dispatch wrapped by middlewares.
+// The chain is built on the first call, so each middleware factory is called only once.
+// Only the first call takes api.mu to see middlewares registered by API.Use.
+func (api *API) handler() http.Handler {
+	api.chainOnce.Do(func() {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+
+		api.chain = api.wrap(http.HandlerFunc(api.dispatch))
+	})
+
+	return api.chain
+}
+
+// dispatch calls API.Handler, or responds 406 Not Acceptable if negotiation failed.
+func (api *API) dispatch(w http.ResponseWriter, r *http.Request) {
+	if rc := FromRequest(r); rc != nil && rc.notAcceptable {
+		api.notAcceptable(w, r)
+		return
+	}
+
+	api.Handler.ServeHTTP(w, r)
+}
+
+// wrap wraps h by middlewares.
+func (api *API) wrap(h http.Handler) http.Handler {
+	for i := len(api.middlewares) - 1; i >= 0; i-- {
+		h = api.middlewares[i](h)
+	}
+
+	return h
+}
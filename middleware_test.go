@@ -0,0 +1,142 @@
+package dou
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type callOrder []string
+
+func (c *callOrder) middleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*c = append(*c, name+":in")
+			next.ServeHTTP(w, r)
+			*c = append(*c, name+":out")
+		})
+	}
+}
+
+func newTestMiddlewareAPI(c *callOrder) *API {
+	a := newTestAPI()
+	a.LogStackTrace = false
+
+	a.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		*c = append(*c, "before")
+		return a.Plugin.BeforeDispatch(w, r)
+	}
+
+	a.AfterDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+		*c = append(*c, "after")
+		return a.Plugin.AfterDispatch(w, r)
+	}
+
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*c = append(*c, "handler")
+	})
+
+	return a
+}
+
+func TestUseCallsMiddlewaresInOrder(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	c := &callOrder{}
+
+	a := newTestMiddlewareAPI(c)
+	a.Use(c.middleware("1"), c.middleware("2"))
+	a.Use(c.middleware("3"))
+
+	a.ServeHTTP(response, request)
+
+	expected := callOrder{"before", "1:in", "2:in", "3:in", "handler", "3:out", "2:out", "1:out", "after"}
+
+	if !reflect.DeepEqual(*c, expected) {
+		t.Errorf("middlewares should be called in order\nexpected: %v\ngot: %v\n", expected, *c)
+	}
+}
+
+func TestMiddlewareCanShortCircuit(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	c := &callOrder{}
+
+	a := newTestMiddlewareAPI(c)
+	a.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*c = append(*c, "deny")
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}, c.middleware("never"))
+
+	a.ServeHTTP(response, request)
+
+	expected := callOrder{"before", "deny", "after"}
+
+	if !reflect.DeepEqual(*c, expected) {
+		t.Errorf("middleware should be able to short-circuit\nexpected: %v\ngot: %v\n", expected, *c)
+	}
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("middleware should be able to write response\nexpected: %v\ngot: %v\n", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestPanicInMiddlewareIsRecovered(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	c := &callOrder{}
+
+	a := newTestMiddlewareAPI(c)
+	a.Use(c.middleware("1"), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("<test panic>")
+		})
+	})
+
+	a.ServeHTTP(response, request)
+
+	expected := callOrder{"before", "1:in", "after"}
+
+	if !reflect.DeepEqual(*c, expected) {
+		t.Errorf("panic in middleware should be recovered\nexpected: %v\ngot: %v\n", expected, *c)
+	}
+
+	if !a.Plugin.(*testAPI).recoverCalled {
+		t.Error("Plugin.OnPanic should be called if panic occur in middleware")
+	}
+}
+
+func TestMiddlewareChainIsBuiltOnce(t *testing.T) {
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	built := 0
+
+	a.Use(func(next http.Handler) http.Handler {
+		built++
+		return next
+	})
+
+	for i := 0; i < 3; i++ {
+		request, _ := http.NewRequest("GET", "/", nil)
+		a.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	if built != 1 {
+		t.Errorf("middleware factory should be called once\nexpected: %v\ngot: %v\n", 1, built)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("API.Use should panic after API started serving")
+		}
+	}()
+
+	a.Use(func(next http.Handler) http.Handler { return next })
+}
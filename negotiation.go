@@ -0,0 +1,170 @@
+package dou
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MediaTyper is implemented by Plugin that can be selected by content negotiation.
+// MediaTypes returns media types that the plugin renders. e.g. []string{"application/json"}
+type MediaTyper interface {
+	MediaTypes() []string
+}
+
+// NewNegotiatingAPI new and initialize API that selects a plugin per request by Accept header.
+// The plugin named defaultPluginName is used when Accept header is empty, and when no plugin is acceptable it responds 406 Not Acceptable.
+// All of pluginNames should implement MediaTyper. Plugins registered by RegisterFactory are created for the API with empty Config.
+func NewNegotiatingAPI(defaultPluginName string, pluginNames ...string) (*API, error) {
+	return NewNegotiatingAPIWithConfig(Config{}, defaultPluginName, pluginNames...)
+}
+
+// NewNegotiatingAPIWithConfig is NewNegotiatingAPI with config.
+// Plugins registered by RegisterFactory are created with config like NewAPIWithConfig.
+func NewNegotiatingAPIWithConfig(config Config, defaultPluginName string, pluginNames ...string) (*API, error) {
+	api, err := NewAPIWithConfig(defaultPluginName, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := api.Plugin.(MediaTyper); ok {
+		api.Plugins = append(api.Plugins, api.Plugin)
+	}
+
+	for _, name := range pluginNames {
+		if name == defaultPluginName {
+			continue
+		}
+
+		plugin, err := newPlugin(name, api.Config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := plugin.(MediaTyper); !ok {
+			return nil, fmt.Errorf("github.com/ToQoz/dou: plugin %q can't be negotiated. It should implement dou.MediaTyper", name)
+		}
+
+		api.Plugins = append(api.Plugins, plugin)
+	}
+
+	return api, nil
+}
+
+// negotiate selects the most acceptable plugin from api.Plugins.
+// When Accept header is empty or has no valid media range, this selects api.Plugin.
+// Ties are broken by order of api.Plugins.
+func (api *API) negotiate(r *http.Request) (Plugin, bool) {
+	accept := r.Header.Get("Accept")
+
+	if accept == "" {
+		return api.Plugin, true
+	}
+
+	ranges := parseAccept(accept)
+
+	if len(ranges) == 0 {
+		// All media ranges are malformed. Treat as empty Accept header.
+		return api.Plugin, true
+	}
+
+	var (
+		best  Plugin
+		bestQ float64
+	)
+
+	for _, plugin := range api.Plugins {
+		mt, ok := plugin.(MediaTyper)
+
+		if !ok {
+			continue
+		}
+
+		for _, t := range mt.MediaTypes() {
+			if q := qualityOf(ranges, t); q > bestQ {
+				best, bestQ = plugin, q
+			}
+		}
+	}
+
+	return best, best != nil
+}
+
+// notAcceptable responds 406 Not Acceptable by api.Plugin.
+func (api *API) notAcceptable(w http.ResponseWriter, r *http.Request) {
+	api.Fail(w, NewError(http.StatusNotAcceptable, "", ""))
+}
+
+// acceptRange is a media range in Accept header. e.g. text/*;q=0.5
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// specificity returns how specific the range matches type/subtype. -1 means no match.
+func (ar acceptRange) specificity(typ, subtype string) int {
+	switch {
+	case ar.typ == "*" && ar.subtype == "*":
+		return 0
+	case ar.typ == typ && ar.subtype == "*":
+		return 1
+	case ar.typ == typ && ar.subtype == subtype:
+		return 2
+	default:
+		return -1
+	}
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+
+		if !ok {
+			continue
+		}
+
+		ar := acceptRange{typ: typ, subtype: subtype, q: 1}
+
+		if v, ok := params["q"]; ok {
+			q, err := strconv.ParseFloat(v, 64)
+
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+
+			ar.q = q
+		}
+
+		ranges = append(ranges, ar)
+	}
+
+	return ranges
+}
+
+// qualityOf returns q-value of mediaType by the most specific range in ranges.
+func qualityOf(ranges []acceptRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+
+	q, specificity := 0.0, -1
+
+	for _, ar := range ranges {
+		if s := ar.specificity(typ, subtype); s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+
+	return q
+}
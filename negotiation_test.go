@@ -0,0 +1,149 @@
+package dou
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mediaTypedAPI struct {
+	testAPI
+	name       string
+	mediaTypes []string
+}
+
+func (p *mediaTypedAPI) MediaTypes() []string {
+	return p.mediaTypes
+}
+
+func (p *mediaTypedAPI) Marshal(v interface{}) ([]byte, error) {
+	return []byte(p.name), nil
+}
+
+func newTestNegotiatingAPI() *API {
+	Register("testjson", &mediaTypedAPI{name: "json", mediaTypes: []string{"application/json"}})
+	Register("testxml", &mediaTypedAPI{name: "xml", mediaTypes: []string{"application/xml", "text/xml"}})
+
+	defer Deregister("testjson")
+	defer Deregister("testxml")
+
+	a, err := NewNegotiatingAPI("testjson", "testxml")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Ok(w, "", http.StatusOK)
+	})
+
+	return a
+}
+
+func TestNegotiation(t *testing.T) {
+	tests := []struct {
+		accept       string
+		expectedCode int
+		expectedBody string
+	}{
+		{"", http.StatusOK, "json"},
+		{"*/*", http.StatusOK, "json"},
+		{"application/json", http.StatusOK, "json"},
+		{"application/xml", http.StatusOK, "xml"},
+		{"text/*", http.StatusOK, "xml"},
+		{"application/json;q=0.5, text/xml", http.StatusOK, "xml"},
+		{"application/json;q=0.9, application/xml;q=0.8", http.StatusOK, "json"},
+		{"application/*;q=0.5, application/xml;q=0", http.StatusOK, "json"},
+		{"*/*;q=0.1, application/json;q=0", http.StatusOK, "xml"},
+		{"image/png", http.StatusNotAcceptable, "json"},
+		{"application/json;q=0", http.StatusNotAcceptable, "json"},
+		{"text/xml;q=2, ;;", http.StatusOK, "json"},
+		{"garbage", http.StatusOK, "json"},
+	}
+
+	a := newTestNegotiatingAPI()
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept", test.accept)
+		response := httptest.NewRecorder()
+
+		a.ServeHTTP(response, request)
+
+		if response.Code != test.expectedCode {
+			t.Errorf("Accept: %q\nexpected code: %v\ngot: %v\n", test.accept, test.expectedCode, response.Code)
+		}
+
+		if got := string(response.Body.Bytes()); got != test.expectedBody && got != test.expectedBody+"\n" {
+			t.Errorf("Accept: %q\nexpected body: %v\ngot: %v\n", test.accept, test.expectedBody, got)
+		}
+
+		if got := response.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("Accept: %q\nexpected Vary: %v\ngot: %v\n", test.accept, "Accept", got)
+		}
+	}
+}
+
+func TestNegotiatedPluginIsUsedForHooks(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept", "application/xml")
+	response := httptest.NewRecorder()
+
+	a := newTestNegotiatingAPI()
+	a.LogStackTrace = false
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("<test panic>")
+	})
+
+	a.ServeHTTP(response, request)
+
+	xml := a.Plugins[1].(*mediaTypedAPI)
+	json := a.Plugins[0].(*mediaTypedAPI)
+
+	if !xml.beforeDispatchCalled || !xml.recoverCalled || !xml.afterDispatchCalled {
+		t.Error("negotiated plugin's BeforeDispatch, OnPanic and AfterDispatch should be called")
+	}
+
+	if json.beforeDispatchCalled || json.recoverCalled || json.afterDispatchCalled {
+		t.Error("default plugin should not be called if another plugin is negotiated")
+	}
+}
+
+func TestNewNegotiatingAPIRejectsPluginWithoutMediaTypes(t *testing.T) {
+	Register("testjson", &mediaTypedAPI{name: "json", mediaTypes: []string{"application/json"}})
+	Register("testapi", &testAPI{})
+
+	defer Deregister("testjson")
+	defer Deregister("testapi")
+
+	if _, err := NewNegotiatingAPI("testjson", "testapi"); err == nil {
+		t.Error("NewNegotiatingAPI should return error if plugin doesn't implement MediaTyper")
+	}
+
+	if _, err := NewNegotiatingAPI("testjson", "unknown"); err == nil {
+		t.Error("NewNegotiatingAPI should return error if plugin is unknown")
+	}
+}
+
+func TestNewNegotiatingAPIWithConfig(t *testing.T) {
+	factory := func(config Config) (Plugin, error) {
+		name, _ := config["name"].(string)
+		return &mediaTypedAPI{name: name, mediaTypes: []string{"application/xml"}}, nil
+	}
+
+	Register("testjson", &mediaTypedAPI{name: "json", mediaTypes: []string{"application/json"}})
+	RegisterFactory("testxml", factory)
+
+	defer Deregister("testjson")
+	defer Deregister("testxml")
+
+	a, err := NewNegotiatingAPIWithConfig(Config{"name": "configured"}, "testjson", "testxml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := a.Plugins[1].(*mediaTypedAPI).name; name != "configured" {
+		t.Errorf("negotiated factory plugin should be created with config\nexpected: %v\ngot: %v\n", "configured", name)
+	}
+}
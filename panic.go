@@ -0,0 +1,47 @@
+package dou
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Phase is a phase of API.ServeHTTP.
+type Phase int
+
+// Phases of API.ServeHTTP.
+const (
+	PhaseBeforeDispatch Phase = iota + 1 // API.BeforeDispatch
+	PhaseHandler                         // middlewares and API.Handler
+	PhaseAfterDispatch                   // API.AfterDispatch
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseBeforeDispatch:
+		return "BeforeDispatch"
+	case PhaseHandler:
+		return "Handler"
+	case PhaseAfterDispatch:
+		return "AfterDispatch"
+	default:
+		return fmt.Sprintf("Phase(%d)", int(p))
+	}
+}
+
+// Panic describes panic recovered in API.ServeHTTP.
+// It is set to RequestContext.Panic before calling API.OnPanic.
+type Panic struct {
+	Value interface{} // value passed to panic()
+	Stack []byte      // full stack trace of the panicking goroutine
+	Phase Phase       // phase that panicked
+}
+
+func (p *Panic) String() string {
+	return fmt.Sprintf("panic in %s: %v", p.Phase, p.Value)
+}
+
+// PanicHandler is implemented by Plugin that wants to know what happened.
+// If Plugin implements this, default API.OnPanic calls HandlePanic instead of Plugin.OnPanic.
+type PanicHandler interface {
+	HandlePanic(w http.ResponseWriter, r *http.Request, p *Panic)
+}
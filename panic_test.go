@@ -0,0 +1,138 @@
+package dou
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type panicHandlerAPI struct {
+	testAPI
+	panics []*Panic
+}
+
+func (p *panicHandlerAPI) HandlePanic(w http.ResponseWriter, r *http.Request, pn *Panic) {
+	p.panics = append(p.panics, pn)
+}
+
+func newTestPanicHandlerAPI() (*API, *panicHandlerAPI) {
+	plugin := &panicHandlerAPI{}
+
+	Register("testpanic", plugin)
+
+	defer Deregister("testpanic")
+
+	a, err := NewAPI("testpanic")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a.LogStackTrace = false
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	return a, plugin
+}
+
+func panicInHandler(w http.ResponseWriter, r *http.Request) {
+	panic("<handler panic>")
+}
+
+func TestHandlePanicReceivesPanic(t *testing.T) {
+	tests := []struct {
+		setup         func(a *API)
+		expectedValue string
+		expectedPhase Phase
+	}{
+		{
+			func(a *API) {
+				a.BeforeDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+					panic("<before panic>")
+				}
+			},
+			"<before panic>",
+			PhaseBeforeDispatch,
+		},
+		{
+			func(a *API) {
+				a.Handler = http.HandlerFunc(panicInHandler)
+			},
+			"<handler panic>",
+			PhaseHandler,
+		},
+		{
+			func(a *API) {
+				a.AfterDispatch = func(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+					panic("<after panic>")
+				}
+			},
+			"<after panic>",
+			PhaseAfterDispatch,
+		},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", "/", nil)
+		response := httptest.NewRecorder()
+
+		a, plugin := newTestPanicHandlerAPI()
+		test.setup(a)
+
+		a.ServeHTTP(response, request)
+
+		if len(plugin.panics) != 1 {
+			t.Errorf("HandlePanic should be called once, but called %d times", len(plugin.panics))
+			continue
+		}
+
+		p := plugin.panics[0]
+
+		if p.Value != test.expectedValue || p.Phase != test.expectedPhase {
+			t.Errorf("HandlePanic should receive panic value and phase\nexpected: %v %v\ngot: %v %v\n", test.expectedValue, test.expectedPhase, p.Value, p.Phase)
+		}
+
+		if plugin.recoverCalled {
+			t.Error("Plugin.OnPanic should not be called if plugin implements PanicHandler")
+		}
+	}
+}
+
+func TestHandlePanicReceivesFullStack(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a, plugin := newTestPanicHandlerAPI()
+	a.Handler = http.HandlerFunc(panicInHandler)
+
+	a.ServeHTTP(response, request)
+
+	if len(plugin.panics) != 1 {
+		t.Fatalf("HandlePanic should be called once, but called %d times", len(plugin.panics))
+	}
+
+	if !bytes.Contains(plugin.panics[0].Stack, []byte("panicInHandler")) {
+		t.Errorf("Panic.Stack should contain panicking function\ngot: %s\n", plugin.panics[0].Stack)
+	}
+}
+
+func TestOverriddenOnPanicCanReadPanic(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a := newTestAPI()
+	a.LogStackTrace = false
+	a.Handler = http.HandlerFunc(panicInHandler)
+
+	var got *Panic
+
+	a.OnPanic = func(w http.ResponseWriter, r *http.Request) {
+		got = FromRequest(r).Panic
+	}
+
+	a.ServeHTTP(response, request)
+
+	if got == nil || got.Value != "<handler panic>" || got.Phase != PhaseHandler {
+		t.Errorf("API.OnPanic should be able to read Panic by FromRequest, but got %v", got)
+	}
+}
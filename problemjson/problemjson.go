@@ -0,0 +1,191 @@
+// Package problemjson is dou plugin that renders error responses as RFC 7807 problem details.
+// Ok responses are plain JSON as same as github.com/ToQoz/dou/jsonapi.
+// See https://tools.ietf.org/html/rfc7807
+package problemjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ToQoz/dou"
+	"net/http"
+	"strconv"
+)
+
+// ContentType is Content-Type of error responses.
+const ContentType = "application/problem+json"
+
+// Register this plugin as "problemjson"
+func init() {
+	dou.Register("problemjson", &problemJSON{})
+}
+
+// Problem is RFC 7807 problem details object.
+// Code, InvalidParams and RequestID are extension members.
+type Problem struct {
+	Type          string           `json:"type"`
+	Title         string           `json:"title"`
+	Status        int              `json:"status"`
+	Detail        string           `json:"detail,omitempty"`
+	Instance      string           `json:"instance,omitempty"`
+	Code          string           `json:"code,omitempty"`
+	InvalidParams []dou.FieldError `json:"invalid-params,omitempty"`
+	RequestID     string           `json:"request_id,omitempty"`
+}
+
+// NewProblem new Problem from v that is given to API.Error.
+//
+//	*Problem   -> copied. Blank members are filled.
+//	*dou.Error -> Message is used as detail. Cause is never rendered.
+//	string     -> used as detail.
+//	others     -> only type, title and status. Raw errors are not rendered, because they may leak internals.
+func NewProblem(v interface{}, status int, instance string) *Problem {
+	p := &Problem{}
+
+	switch v := v.(type) {
+	case *Problem:
+		*p = *v
+	case *dou.Error:
+		p.Code = v.Code
+		p.Detail = v.Message
+		p.InvalidParams = v.Details
+	case string:
+		p.Detail = v
+	}
+
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+
+	if p.Status == 0 {
+		p.Status = status
+	}
+
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	if p.Instance == "" {
+		p.Instance = instance
+	}
+
+	return p
+}
+
+type problemJSON struct{}
+
+// problemWriter keeps request for problem's instance member.
+type problemWriter struct {
+	http.ResponseWriter
+	r *http.Request
+}
+
+// Unwrap returns underlying http.ResponseWriter.
+func (pw *problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// Flush flushes underlying http.ResponseWriter if it implements http.Flusher.
+func (pw *problemWriter) Flush() {
+	if f, ok := pw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instanceOf returns request URI of w if it is known.
+func instanceOf(w http.ResponseWriter) string {
+	for {
+		switch x := w.(type) {
+		case *problemWriter:
+			return x.r.URL.RequestURI()
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = x.Unwrap()
+		default:
+			return ""
+		}
+	}
+}
+
+// BeforeDispatch is default func for before dispatch.
+func (pj *problemJSON) BeforeDispatch(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return dou.WrapWriter(&problemWriter{ResponseWriter: w, r: r}, w), r
+}
+
+// AfterDispatch is default func for after dispatch.
+func (pj *problemJSON) AfterDispatch(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	return w, r
+}
+
+// OnPanic is called when panic occur.
+func (pj *problemJSON) OnPanic(w http.ResponseWriter, r *http.Request) {
+	// if api.SafeWriter.Write or WriteHeader called before occuring panic,
+	// this will not write response body and header.
+	// see also github.com/ToQoz/dou/jsonapi
+	if sw, ok := dou.SafeWriterOf(w); ok {
+		if sw.Wrote || sw.WroteHeader {
+			return
+		}
+	}
+
+	var b string
+
+	p := NewProblem(nil, http.StatusInternalServerError, r.URL.RequestURI())
+
+	// Return request id for correlating client reports with logs.
+	if rc := dou.FromRequest(r); rc != nil {
+		p.RequestID = rc.RequestID
+	}
+
+	j, err := json.Marshal(p)
+
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		b = http.StatusText(http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", ContentType)
+		b = string(j)
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+
+	_, err = fmt.Fprintln(w, b)
+
+	if err != nil {
+		// Skip error
+		// http.Error skip this error too.
+		dou.ReportWriteError(r, err)
+	}
+}
+
+// MediaTypes returns media types for content negotiation.
+func (pj *problemJSON) MediaTypes() []string {
+	return []string{"application/json", ContentType}
+}
+
+// Marshal a interface to a JSON.
+func (pj *problemJSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// MarshalError a interface to a problem details JSON and sets Content-Type.
+func (pj *problemJSON) MarshalError(w http.ResponseWriter, v interface{}, httpStatusCode int) ([]byte, error) {
+	b, err := json.Marshal(NewProblem(v, httpStatusCode, instanceOf(w)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	return b, nil
+}
+
+// Unmarshal JSON to a interface.
+func (pj *problemJSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// APIStatus sets code to X-API-Status header.
+// see also github.com/ToQoz/dou/jsonapi
+func (pj *problemJSON) APIStatus(w http.ResponseWriter, code int) {
+	w.Header().Set("X-API-Status", strconv.Itoa(code))
+}
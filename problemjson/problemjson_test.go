@@ -0,0 +1,187 @@
+package problemjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ToQoz/dou"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestAPI(h func(a *dou.API) http.HandlerFunc) *dou.API {
+	a, err := dou.NewAPI("problemjson")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a.LogStackTrace = false
+	a.Handler = h(a)
+
+	return a
+}
+
+// Ok should write plain JSON
+func TestOkWritePlainJSON(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a := newTestAPI(func(a *dou.API) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			a.Ok(w, map[string]string{"hello": "world"}, http.StatusOK)
+		}
+	})
+
+	a.ServeHTTP(response, request)
+
+	if got := response.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("Ok should write application/json, but got %v", got)
+	}
+
+	if got := response.Body.String(); got != `{"hello":"world"}` {
+		t.Errorf("Ok should write plain JSON, but got %v", got)
+	}
+}
+
+// Error should write problem details
+func TestErrorWriteProblem(t *testing.T) {
+	tests := []struct {
+		resource interface{}
+		expected map[string]interface{}
+	}{
+		{
+			&dou.Error{HTTPStatus: 422, Code: "validation_error", Message: "Validation failed", Details: []dou.FieldError{{Field: "name", Message: "name is required"}}},
+			map[string]interface{}{
+				"type":           "about:blank",
+				"title":          "Unprocessable Entity",
+				"status":         float64(422),
+				"detail":         "Validation failed",
+				"instance":       "/users?dry=1",
+				"code":           "validation_error",
+				"invalid-params": []interface{}{map[string]interface{}{"field": "name", "message": "name is required"}},
+			},
+		},
+		{
+			&Problem{Type: "https://example.com/probs/out-of-credit", Title: "You do not have enough credit."},
+			map[string]interface{}{
+				"type":     "https://example.com/probs/out-of-credit",
+				"title":    "You do not have enough credit.",
+				"status":   float64(422),
+				"instance": "/users?dry=1",
+			},
+		},
+		{
+			"Name is already taken",
+			map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Unprocessable Entity",
+				"status":   float64(422),
+				"detail":   "Name is already taken",
+				"instance": "/users?dry=1",
+			},
+		},
+		{
+			fmt.Errorf("query users: %w", errors.New("pq: relation \"users\" does not exist")),
+			map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Unprocessable Entity",
+				"status":   float64(422),
+				"instance": "/users?dry=1",
+			},
+		},
+		{
+			map[string]string{"message": "unknown"},
+			map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Unprocessable Entity",
+				"status":   float64(422),
+				"instance": "/users?dry=1",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("POST", "/users?dry=1", nil)
+		response := httptest.NewRecorder()
+
+		resource := test.resource
+		a := newTestAPI(func(a *dou.API) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				a.Error(w, resource, 422)
+			}
+		})
+
+		a.ServeHTTP(response, request)
+
+		if got := response.Header().Get("Content-Type"); got != ContentType {
+			t.Errorf("Error should write %v, but got %v", ContentType, got)
+		}
+
+		if response.Code != 422 {
+			t.Errorf("Error should write given status code, but got %v", response.Code)
+		}
+
+		got := map[string]interface{}{}
+
+		if err := json.Unmarshal(response.Body.Bytes(), &got); err != nil {
+			panic(err)
+		}
+
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Error wrote invalid problem\nexpected: %v\ngot: %v\n", test.expected, got)
+		}
+	}
+}
+
+// OnPanic should write problem details
+func TestOnPanicWriteProblem(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/panic", nil)
+	response := httptest.NewRecorder()
+
+	a := newTestAPI(func(a *dou.API) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			panic("<test panic>")
+		}
+	})
+
+	a.ServeHTTP(response, request)
+
+	if got := response.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("OnPanic should write %v, but got %v", ContentType, got)
+	}
+
+	got := &Problem{}
+
+	if err := json.Unmarshal(response.Body.Bytes(), got); err != nil {
+		panic(err)
+	}
+
+	expected := &Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Instance: "/panic"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("OnPanic wrote invalid problem\nexpected: %v\ngot: %v\n", expected, got)
+	}
+}
+
+// OnPanic should not write if response is already written before panic occur
+func TestOnPanicDontWriteIfResponseIsAlreadyWrittenBeforePanicOccur(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	response := httptest.NewRecorder()
+
+	a := newTestAPI(func(a *dou.API) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+			panic("<test panic>")
+		}
+	})
+
+	a.ServeHTTP(response, request)
+
+	if !bytes.Equal(response.Body.Bytes(), []byte("hello")) {
+		t.Error("OnPanic should not write response if response is written before panic occur")
+	}
+}
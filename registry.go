@@ -0,0 +1,197 @@
+package dou
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrNilPlugin is returned by RegisterPlugin and RegisterPluginFactory when plugin is nil.
+	ErrNilPlugin = errors.New("github.com/ToQoz/dou: plugin is nil")
+
+	// ErrDuplicatePlugin is returned by RegisterPlugin and RegisterPluginFactory when the name is already registered.
+	ErrDuplicatePlugin = errors.New("github.com/ToQoz/dou: plugin is already registered")
+
+	// ErrUnknownPlugin is returned when no plugin is registered by the name. It is wrapped by NewAPI's error.
+	ErrUnknownPlugin = errors.New("github.com/ToQoz/dou: unknown plugin")
+)
+
+// PluginFactory creates Plugin for each API from API.Config.
+// Returned error is returned by NewAPIWithConfig. e.g. invalid configuration.
+type PluginFactory func(config Config) (Plugin, error)
+
+// registration is plugin registered by the name.
+// Plugin registered by Register is wrapped in factory that always returns it.
+type registration struct {
+	factory PluginFactory
+}
+
+func singleton(plugin Plugin) *registration {
+	return &registration{
+		factory: func(Config) (Plugin, error) { return plugin, nil },
+	}
+}
+
+// RegisterFactory makes plugin factory available by the provided name.
+// Every API created by NewAPI gets its own plugin created by factory.
+// It panics like Register. see also RegisterPluginFactory
+//
+//	func init() {
+//		dou.RegisterFactory("xmlapi", func(config dou.Config) (dou.Plugin, error) {
+//			indent, _ := config["indent"].(string)
+//			return &xmlAPI{indent: indent}, nil
+//		})
+//	}
+func RegisterFactory(pluginName string, factory PluginFactory) {
+	err := RegisterPluginFactory(pluginName, factory)
+
+	switch err {
+	case ErrNilPlugin:
+		panic("github.com/ToQoz/dou: RegisterFactory factory is nil")
+	case ErrDuplicatePlugin:
+		panic("github.com/ToQoz/dou: RegisterFactory called twice for plugin " + pluginName)
+	}
+}
+
+// RegisterPlugin makes plugin available by the provided name like Register.
+// It returns ErrNilPlugin or ErrDuplicatePlugin instead of panicking.
+func RegisterPlugin(pluginName string, plugin Plugin) error {
+	if plugin == nil {
+		return ErrNilPlugin
+	}
+
+	return register(pluginName, singleton(plugin))
+}
+
+// RegisterPluginFactory makes plugin factory available by the provided name like RegisterFactory.
+// It returns ErrNilPlugin or ErrDuplicatePlugin instead of panicking.
+func RegisterPluginFactory(pluginName string, factory PluginFactory) error {
+	if factory == nil {
+		return ErrNilPlugin
+	}
+
+	return register(pluginName, &registration{factory: factory})
+}
+
+func register(pluginName string, rg *registration) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	if _, dup := plugins[pluginName]; dup {
+		return ErrDuplicatePlugin
+	}
+
+	plugins[pluginName] = rg
+	return nil
+}
+
+// DeregisterPlugin deregisters plugin that registered by the provided name like Deregister.
+// It returns ErrUnknownPlugin if the plugin is not registered.
+func DeregisterPlugin(pluginName string) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	if _, ok := plugins[pluginName]; !ok {
+		return ErrUnknownPlugin
+	}
+
+	delete(plugins, pluginName)
+	return nil
+}
+
+// Replace registers plugin by the provided name even if the name is already registered.
+// It returns func that restores previous registration, whether it was registered by Register or RegisterFactory.
+// This is useful for replacing plugin with stub in tests.
+//
+//	restore := dou.Replace("jsonapi", stub)
+//	defer restore()
+//
+// If plugin is nil, the name is deregistered.
+func Replace(pluginName string, plugin Plugin) (restore func()) {
+	var rg *registration
+
+	if plugin != nil {
+		rg = singleton(plugin)
+	}
+
+	return restorer(pluginName, replace(pluginName, rg))
+}
+
+// ReplaceFactory is Replace for plugin factory.
+func ReplaceFactory(pluginName string, factory PluginFactory) (restore func()) {
+	var rg *registration
+
+	if factory != nil {
+		rg = &registration{factory: factory}
+	}
+
+	return restorer(pluginName, replace(pluginName, rg))
+}
+
+// restorer returns func that registers old by the provided name again. nil old means not registered.
+func restorer(pluginName string, old *registration) func() {
+	return func() {
+		replace(pluginName, old)
+	}
+}
+
+func replace(pluginName string, rg *registration) *registration {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	old := plugins[pluginName]
+
+	if rg == nil {
+		delete(plugins, pluginName)
+	} else {
+		plugins[pluginName] = rg
+	}
+
+	return old
+}
+
+// Lookup returns plugin registered by the provided name.
+// For plugin registered by RegisterFactory, it creates new one with empty Config. ok is false if it fails.
+func Lookup(pluginName string) (Plugin, bool) {
+	plugin, err := newPlugin(pluginName, Config{})
+	return plugin, err == nil
+}
+
+// Plugins returns sorted names of registered plugins.
+func Plugins() []string {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+
+	names := make([]string, 0, len(plugins))
+
+	for name := range plugins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// newPlugin returns plugin registered by the provided name for config.
+func newPlugin(pluginName string, config Config) (Plugin, error) {
+	pluginsMu.RLock()
+	rg, ok := plugins[pluginName]
+	pluginsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q (forgotten import?)", ErrUnknownPlugin, pluginName)
+	}
+
+	plugin, err := rg.factory(config)
+
+	if err != nil {
+		return nil, fmt.Errorf("github.com/ToQoz/dou: fail to create plugin %q: %w", pluginName, err)
+	}
+
+	if plugin == nil {
+		return nil, fmt.Errorf("github.com/ToQoz/dou: fail to create plugin %q: %w", pluginName, ErrNilPlugin)
+	}
+
+	return plugin, nil
+}
@@ -0,0 +1,234 @@
+package dou
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegisterPlugin(t *testing.T) {
+	if err := RegisterPlugin("testregistry", nil); err != ErrNilPlugin {
+		t.Errorf("RegisterPlugin should return ErrNilPlugin if plugin is nil\nexpected: %v\ngot: %v\n", ErrNilPlugin, err)
+	}
+
+	if err := RegisterPlugin("testregistry", &testAPI{}); err != nil {
+		t.Errorf("RegisterPlugin should register plugin, but got %v", err)
+	}
+
+	if err := RegisterPlugin("testregistry", &testAPI{}); err != ErrDuplicatePlugin {
+		t.Errorf("RegisterPlugin should return ErrDuplicatePlugin if the name is registered\nexpected: %v\ngot: %v\n", ErrDuplicatePlugin, err)
+	}
+
+	if err := DeregisterPlugin("testregistry"); err != nil {
+		t.Errorf("DeregisterPlugin should deregister plugin, but got %v", err)
+	}
+
+	if err := DeregisterPlugin("testregistry"); err != ErrUnknownPlugin {
+		t.Errorf("DeregisterPlugin should return ErrUnknownPlugin if the name is not registered\nexpected: %v\ngot: %v\n", ErrUnknownPlugin, err)
+	}
+}
+
+func TestLookupAndPlugins(t *testing.T) {
+	plugin := &testAPI{}
+
+	Register("testregistry2", plugin)
+	Register("testregistry1", &testAPI{})
+
+	defer Deregister("testregistry1")
+	defer Deregister("testregistry2")
+
+	if p, ok := Lookup("testregistry2"); !ok || p != plugin {
+		t.Errorf("Lookup should return registered plugin\nexpected: %v\ngot: %v\n", plugin, p)
+	}
+
+	if _, ok := Lookup("testregistry3"); ok {
+		t.Error("Lookup should report unknown plugin")
+	}
+
+	var names []string
+
+	for _, name := range Plugins() {
+		if name == "testregistry1" || name == "testregistry2" {
+			names = append(names, name)
+		}
+	}
+
+	if expected := []string{"testregistry1", "testregistry2"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Plugins should return sorted names\nexpected: %v\ngot: %v\n", expected, names)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	original := &testAPI{}
+	stub := &testAPI{}
+
+	restoreUnregistered := Replace("testregistry", original)
+	restore := Replace("testregistry", stub)
+
+	a, err := NewAPI("testregistry")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Plugin != stub {
+		t.Errorf("NewAPI should use replaced plugin\nexpected: %v\ngot: %v\n", stub, a.Plugin)
+	}
+
+	restore()
+
+	if p, _ := Lookup("testregistry"); p != original {
+		t.Errorf("restore should register previous plugin again\nexpected: %v\ngot: %v\n", original, p)
+	}
+
+	restoreUnregistered()
+
+	if _, ok := Lookup("testregistry"); ok {
+		t.Error("restore should deregister plugin if it was not registered")
+	}
+
+	Register("testregistry", original)
+	Replace("testregistry", nil)
+
+	if _, ok := Lookup("testregistry"); ok {
+		t.Error("Replace with nil should deregister plugin")
+	}
+}
+
+func TestNewAPIReturnsErrUnknownPlugin(t *testing.T) {
+	if _, err := NewAPI("unknown"); !errors.Is(err, ErrUnknownPlugin) {
+		t.Errorf("NewAPI should return error wrapping ErrUnknownPlugin, but got %v", err)
+	}
+}
+
+func TestRegistryIsSafeForConcurrentUse(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("testconcurrent%d", i)
+			RegisterPlugin(name, &testAPI{})
+			Lookup(name)
+			Plugins()
+			DeregisterPlugin(name)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+type configuredAPI struct {
+	testAPI
+	name string
+}
+
+func configuredFactory(config Config) (Plugin, error) {
+	name, ok := config["name"].(string)
+
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+
+	return &configuredAPI{name: name}, nil
+}
+
+func TestRegisterFactory(t *testing.T) {
+	RegisterFactory("testfactory", configuredFactory)
+
+	defer Deregister("testfactory")
+
+	a1, err := NewAPIWithConfig("testfactory", Config{"name": "a1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := NewAPIWithConfig("testfactory", Config{"name": "a2"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a1.Plugin == a2.Plugin {
+		t.Error("each API should get its own plugin created by factory")
+	}
+
+	if name := a1.Plugin.(*configuredAPI).name; name != "a1" {
+		t.Errorf("factory should receive API.Config\nexpected: %v\ngot: %v\n", "a1", name)
+	}
+
+	if a1.Config["name"] != "a1" {
+		t.Errorf("NewAPIWithConfig should set API.Config\nexpected: %v\ngot: %v\n", "a1", a1.Config["name"])
+	}
+
+	if _, err := NewAPI("testfactory"); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("NewAPI should return error of factory, but got %v", err)
+	}
+
+	if err := RegisterPluginFactory("testfactory", configuredFactory); err != ErrDuplicatePlugin {
+		t.Errorf("RegisterPluginFactory should return ErrDuplicatePlugin if the name is registered\nexpected: %v\ngot: %v\n", ErrDuplicatePlugin, err)
+	}
+
+	if err := RegisterPluginFactory("testfactory2", nil); err != ErrNilPlugin {
+		t.Errorf("RegisterPluginFactory should return ErrNilPlugin if factory is nil\nexpected: %v\ngot: %v\n", ErrNilPlugin, err)
+	}
+}
+
+func TestSingletonIsSharedBetweenAPIs(t *testing.T) {
+	plugin := &testAPI{}
+
+	Register("testsingleton", plugin)
+
+	defer Deregister("testsingleton")
+
+	a1, _ := NewAPI("testsingleton")
+	a2, _ := NewAPIWithConfig("testsingleton", Config{"name": "a2"})
+
+	if a1.Plugin != plugin || a2.Plugin != plugin {
+		t.Error("plugin registered by Register should be shared between APIs")
+	}
+}
+
+func TestReplaceFactory(t *testing.T) {
+	plugin := &testAPI{}
+
+	Register("testreplacefactory", plugin)
+
+	defer Deregister("testreplacefactory")
+
+	restore := ReplaceFactory("testreplacefactory", configuredFactory)
+
+	if _, err := NewAPIWithConfig("testreplacefactory", Config{"name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	restore()
+
+	if p, _ := Lookup("testreplacefactory"); p != plugin {
+		t.Errorf("restore should register previous plugin again\nexpected: %v\ngot: %v\n", plugin, p)
+	}
+
+	// Replacing factory by stub plugin should be restored as factory.
+	restore = ReplaceFactory("testreplacefactory", configuredFactory)
+	defer restore()
+
+	restoreStub := Replace("testreplacefactory", plugin)
+	restoreStub()
+
+	a, err := NewAPIWithConfig("testreplacefactory", Config{"name": "a"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := a.Plugin.(*configuredAPI); !ok {
+		t.Errorf("restore should register previous factory again, but got %v", a.Plugin)
+	}
+}
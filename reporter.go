@@ -0,0 +1,183 @@
+package dou
+
+import (
+	"log"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// Reporter receives failures that can't be responded to client.
+// API.ServeHTTP reports recovered panics, and API.Ok/API.Error report errors of writing response.
+// r may be nil when the request is unknown. e.g. API.Ok is called with http.ResponseWriter that is not served by API.
+type Reporter interface {
+	ReportPanic(r *http.Request, p *Panic)
+	ReportWriteError(r *http.Request, err error)
+}
+
+// LogReporter is Reporter that logs by Logger.
+// If Logger is nil, it logs by standard logger of package log.
+// Use this instead of default structured logging by API.Logger if you need plain text logs.
+type LogReporter struct {
+	Logger *log.Logger
+}
+
+func (lr *LogReporter) printf(format string, v ...interface{}) {
+	if lr.Logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+
+	lr.Logger.Printf(format, v...)
+}
+
+// ReportPanic logs panic with stack trace.
+func (lr *LogReporter) ReportPanic(r *http.Request, p *Panic) {
+	lr.printf("github.com/ToQoz/dou: OnPanic panic in API.ServeHTTP (%s %s, %s, request id: %s): %v\n%s", requestMethod(r), requestPath(r), p.Phase, requestID(r), p.Value, p.Stack)
+}
+
+// ReportWriteError logs err.
+func (lr *LogReporter) ReportWriteError(r *http.Request, err error) {
+	lr.printf("github.com/ToQoz/dou: fail to write response (%s %s)\n%v", requestMethod(r), requestPath(r), err)
+}
+
+// PanicReport is panic reported to MemoryReporter.
+type PanicReport struct {
+	Request *http.Request
+	Panic   *Panic
+}
+
+// WriteErrorReport is write error reported to MemoryReporter.
+type WriteErrorReport struct {
+	Request *http.Request
+	Err     error
+}
+
+// MemoryReporter is Reporter that keeps reports in memory.
+// This is useful for tests.
+type MemoryReporter struct {
+	mu          sync.Mutex
+	panics      []PanicReport
+	writeErrors []WriteErrorReport
+}
+
+// ReportPanic keeps panic.
+func (mr *MemoryReporter) ReportPanic(r *http.Request, p *Panic) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.panics = append(mr.panics, PanicReport{Request: r, Panic: p})
+}
+
+// ReportWriteError keeps err.
+func (mr *MemoryReporter) ReportWriteError(r *http.Request, err error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.writeErrors = append(mr.writeErrors, WriteErrorReport{Request: r, Err: err})
+}
+
+// Panics returns reported panics.
+func (mr *MemoryReporter) Panics() []PanicReport {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return append([]PanicReport(nil), mr.panics...)
+}
+
+// WriteErrors returns reported write errors.
+func (mr *MemoryReporter) WriteErrors() []WriteErrorReport {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return append([]WriteErrorReport(nil), mr.writeErrors...)
+}
+
+// Reset discards reports.
+func (mr *MemoryReporter) Reset() {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.panics = nil
+	mr.writeErrors = nil
+}
+
+// ReportWriteError reports err of writing response for r to API.Reporter of the API serving r.
+// Plugins should use this for errors that can't be returned. e.g. writing response in OnPanic.
+// If r is nil or not served by API, err is logged by LoggerFor(r).
+func ReportWriteError(r *http.Request, err error) {
+	if rc := FromRequest(r); rc != nil && rc.api != nil {
+		rc.api.reportRequestWriteError(r, err)
+		return
+	}
+
+	LoggerFor(r).Error("fail to write response", slog.Any("error", err))
+}
+
+// reportPanic reports p to api.Reporter.
+// If api.Reporter is nil, it logs p by api.Logger when api.LogStackTrace is true.
+func (api *API) reportPanic(r *http.Request, p *Panic) {
+	if api.Reporter != nil {
+		api.Reporter.ReportPanic(r, p)
+		return
+	}
+
+	if !api.LogStackTrace {
+		return
+	}
+
+	api.logger().Error("panic in API.ServeHTTP", append(requestAttrs(r),
+		slog.String("phase", p.Phase.String()),
+		slog.Any("panic", p.Value),
+		slog.String("stack", string(p.Stack)),
+	)...)
+}
+
+// reportWriteError reports err with the request of w. see reportRequestWriteError
+func (api *API) reportWriteError(w http.ResponseWriter, err error) {
+	var r *http.Request
+
+	if sw, ok := SafeWriterOf(w); ok {
+		r = sw.req
+	}
+
+	api.reportRequestWriteError(r, err)
+}
+
+// reportRequestWriteError reports err to api.Reporter, or logs it by api.Logger if api.Reporter is nil.
+func (api *API) reportRequestWriteError(r *http.Request, err error) {
+	if api.Reporter != nil {
+		api.Reporter.ReportWriteError(r, err)
+		return
+	}
+
+	api.logger().Error("fail to write response", append(requestAttrs(r), slog.Any("error", err))...)
+}
+
+func requestMethod(r *http.Request) string {
+	if r == nil {
+		return "-"
+	}
+
+	return r.Method
+}
+
+func requestID(r *http.Request) string {
+	if r == nil {
+		return "-"
+	}
+
+	if rc := FromRequest(r); rc != nil && rc.RequestID != "" {
+		return rc.RequestID
+	}
+
+	return "-"
+}
+
+func requestPath(r *http.Request) string {
+	if r == nil || r.URL == nil {
+		return "-"
+	}
+
+	return r.URL.Path
+}
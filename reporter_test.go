@@ -0,0 +1,131 @@
+package dou
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errBrokenWriter = errors.New("broken writer")
+
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	return 0, errBrokenWriter
+}
+
+func TestReporterReceivesPanic(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/panic", nil)
+	response := httptest.NewRecorder()
+
+	reporter := &MemoryReporter{}
+
+	a := newTestAPI()
+	a.Reporter = reporter
+	a.Handler = http.HandlerFunc(panicInHandler)
+
+	// LogStackTrace affects only default logging.
+	a.LogStackTrace = false
+
+	a.ServeHTTP(response, request)
+
+	panics := reporter.Panics()
+
+	if len(panics) != 1 {
+		t.Fatalf("Reporter.ReportPanic should be called once, but called %d times", len(panics))
+	}
+
+	if panics[0].Request.URL.Path != "/panic" || panics[0].Panic.Value != "<handler panic>" || panics[0].Panic.Phase != PhaseHandler {
+		t.Errorf("Reporter.ReportPanic should receive request and panic, but got %v %v", panics[0].Request.URL, panics[0].Panic)
+	}
+
+	reporter.Reset()
+
+	if len(reporter.Panics()) != 0 {
+		t.Error("MemoryReporter.Reset should discard reports")
+	}
+}
+
+func TestReporterReceivesWriteError(t *testing.T) {
+	for _, write := range []func(a *API, w http.ResponseWriter){
+		func(a *API, w http.ResponseWriter) { a.Ok(w, "", http.StatusOK) },
+		func(a *API, w http.ResponseWriter) { a.Error(w, "", http.StatusInternalServerError) },
+	} {
+		request, _ := http.NewRequest("GET", "/write", nil)
+		response := &brokenWriter{httptest.NewRecorder()}
+
+		reporter := &MemoryReporter{}
+
+		a := newTestAPI()
+		a.Reporter = reporter
+
+		write := write
+		a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			write(a, w)
+		})
+
+		a.ServeHTTP(response, request)
+
+		writeErrors := reporter.WriteErrors()
+
+		if len(writeErrors) != 1 {
+			t.Errorf("Reporter.ReportWriteError should be called once, but called %d times", len(writeErrors))
+			continue
+		}
+
+		if writeErrors[0].Request.URL.Path != "/write" || writeErrors[0].Err != errBrokenWriter {
+			t.Errorf("Reporter.ReportWriteError should receive request and error, but got %v %v", writeErrors[0].Request.URL, writeErrors[0].Err)
+		}
+	}
+}
+
+func TestReportWriteError(t *testing.T) {
+	reporter := &MemoryReporter{}
+
+	a := newTestAPI()
+	a.Reporter = reporter
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportWriteError(r, errBrokenWriter)
+	})
+
+	request, _ := http.NewRequest("GET", "/write", nil)
+	a.ServeHTTP(httptest.NewRecorder(), request)
+
+	if writeErrors := reporter.WriteErrors(); len(writeErrors) != 1 || writeErrors[0].Err != errBrokenWriter {
+		t.Errorf("ReportWriteError should report to Reporter of API serving the request, but got %v", writeErrors)
+	}
+
+	logger, buf := newTestLogger()
+	request = request.WithContext(NewRequestContext(request.Context(), &RequestContext{Logger: logger}))
+
+	ReportWriteError(request, errBrokenWriter)
+
+	if records := decodeLogRecords(buf); len(records) != 1 || records[0]["error"] != errBrokenWriter.Error() {
+		t.Errorf("ReportWriteError should log if the request is not served by API, but got %v", records)
+	}
+
+	// Must not panic.
+	ReportWriteError(nil, errBrokenWriter)
+}
+
+func TestLogReporter(t *testing.T) {
+	request, _ := http.NewRequest("POST", "/users", nil)
+
+	buf := &bytes.Buffer{}
+	lr := &LogReporter{Logger: log.New(buf, "", 0)}
+
+	lr.ReportPanic(request, &Panic{Value: "<test panic>", Stack: []byte("goroutine 1"), Phase: PhaseHandler})
+	lr.ReportWriteError(nil, errBrokenWriter)
+
+	for _, expected := range []string{"POST /users", "Handler", "<test panic>", "goroutine 1", errBrokenWriter.Error()} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("LogReporter should log %q\ngot: %s\n", expected, buf.String())
+		}
+	}
+}
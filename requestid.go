@@ -0,0 +1,61 @@
+package dou
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is conventional header name for API.RequestIDHeader.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits length of incoming request id.
+const maxRequestIDLength = 128
+
+// NewRequestID returns random 128 bit request id in hex.
+func NewRequestID() string {
+	b := make([]byte, 16)
+
+	// crypto/rand.Read never returns error.
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// assignRequestID sets request id to rc, and echos it in response header.
+// Incoming request id in api.RequestIDHeader is used if it is valid.
+func (api *API) assignRequestID(w http.ResponseWriter, r *http.Request, rc *RequestContext) {
+	id := r.Header.Get(api.RequestIDHeader)
+
+	if !validRequestID(id) {
+		if api.GenerateRequestID != nil {
+			id = api.GenerateRequestID()
+		} else {
+			id = NewRequestID()
+		}
+	}
+
+	rc.RequestID = id
+	w.Header().Set(api.RequestIDHeader, id)
+}
+
+// validRequestID reports whether id is safe to echo and log.
+// It accepts up to 128 characters of letters, digits and "-_.:+/=".
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
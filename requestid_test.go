@@ -0,0 +1,80 @@
+package dou
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		incoming string
+		expected string
+	}{
+		{"", "generated"},
+		{"abc-123_DEF.4:5", "abc-123_DEF.4:5"},
+		{"invalid request id", "generated"},
+		{"<script>", "generated"},
+		{strings.Repeat("a", 129), "generated"},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", "/", nil)
+		response := httptest.NewRecorder()
+
+		if test.incoming != "" {
+			request.Header.Set("X-Request-ID", test.incoming)
+		}
+
+		var got string
+
+		a := newTestAPI()
+		a.RequestIDHeader = DefaultRequestIDHeader
+		a.GenerateRequestID = func() string { return "generated" }
+		a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = FromRequest(r).RequestID
+		})
+
+		a.ServeHTTP(response, request)
+
+		if got != test.expected {
+			t.Errorf("incoming request id %q\nexpected: %v\ngot: %v\n", test.incoming, test.expected, got)
+		}
+
+		if echoed := response.Header().Get("X-Request-ID"); echoed != test.expected {
+			t.Errorf("request id should be echoed in response header\nexpected: %v\ngot: %v\n", test.expected, echoed)
+		}
+	}
+}
+
+func TestRequestIDIsDisabledByDefault(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Set("X-Request-ID", "abc")
+	response := httptest.NewRecorder()
+
+	var got string
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromRequest(r).RequestID
+	})
+
+	a.ServeHTTP(response, request)
+
+	if got != "" || response.Header().Get("X-Request-ID") != "" {
+		t.Errorf("request id should not be assigned unless API.RequestIDHeader is set, but got %q", got)
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	id := NewRequestID()
+
+	if len(id) != 32 || !validRequestID(id) {
+		t.Errorf("NewRequestID should return 32 hex characters, but got %q", id)
+	}
+
+	if id == NewRequestID() {
+		t.Error("NewRequestID should return random id")
+	}
+}
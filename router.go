@@ -0,0 +1,203 @@
+package dou
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router is simple http.Handler that dispatches requests by method and path pattern.
+// Pattern is slash separated segments. A segment starting with ":" matches any one segment,
+// and a segment starting with "*" matches the rest of path. They are retrieved by Param.
+//
+//	router := dou.NewRouter(api)
+//	router.Get("/users/:id", showUser)
+//	router.Get("/files/*path", serveFile)
+//
+// When no route matches, it responds 404 Not Found, or 405 Method Not Allowed with Allow header when the path matches with another method.
+// They are rendered by API.Fail, so bodies are formatted by the current plugin.
+type Router struct {
+	api    *API
+	prefix string
+	routes *[]*route // shared with groups
+}
+
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  http.Handler
+}
+
+type paramsKey struct{}
+
+// NewRouter new Router that renders 404/405 by api.
+func NewRouter(api *API) *Router {
+	return &Router{api: api, routes: &[]*route{}}
+}
+
+// Group returns Router that registers routes with prefix.
+// Routes registered through the group are served by the parent router.
+func (rt *Router) Group(prefix string) *Router {
+	return &Router{api: rt.api, prefix: rt.prefix + strings.TrimSuffix(prefix, "/"), routes: rt.routes}
+}
+
+// Handle registers handler for method and pattern.
+func (rt *Router) Handle(method, pattern string, handler http.Handler) {
+	*rt.routes = append(*rt.routes, &route{
+		method:   method,
+		pattern:  rt.prefix + pattern,
+		segments: splitPath(rt.prefix + pattern),
+		handler:  handler,
+	})
+}
+
+// HandleFunc registers handler func for method and pattern.
+func (rt *Router) HandleFunc(method, pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+	rt.Handle(method, pattern, http.HandlerFunc(handler))
+}
+
+// Get registers handler for GET. HEAD request is served by it too.
+func (rt *Router) Get(pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+	rt.HandleFunc(http.MethodGet, pattern, handler)
+}
+
+// Post registers handler for POST.
+func (rt *Router) Post(pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+	rt.HandleFunc(http.MethodPost, pattern, handler)
+}
+
+// Put registers handler for PUT.
+func (rt *Router) Put(pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+	rt.HandleFunc(http.MethodPut, pattern, handler)
+}
+
+// Patch registers handler for PATCH.
+func (rt *Router) Patch(pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+	rt.HandleFunc(http.MethodPatch, pattern, handler)
+}
+
+// Delete registers handler for DELETE.
+func (rt *Router) Delete(pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+	rt.HandleFunc(http.MethodDelete, pattern, handler)
+}
+
+// ServeHTTP dispatches request to the first route matching method and path.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := splitPath(r.URL.Path)
+
+	var (
+		fallback       *route
+		fallbackParams map[string]string
+		allowed        []string
+	)
+
+	for _, rr := range *rt.routes {
+		params, ok := rr.match(path)
+
+		if !ok {
+			continue
+		}
+
+		if rr.method == r.Method {
+			rr.serve(w, r, params)
+			return
+		}
+
+		if r.Method == http.MethodHead && rr.method == http.MethodGet && fallback == nil {
+			fallback, fallbackParams = rr, params
+		}
+
+		allowed = appendMethod(allowed, rr.method)
+
+		if rr.method == http.MethodGet {
+			allowed = appendMethod(allowed, http.MethodHead)
+		}
+	}
+
+	if fallback != nil {
+		fallback.serve(w, r, fallbackParams)
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		rt.api.Fail(w, NewError(http.StatusMethodNotAllowed, "", ""))
+		return
+	}
+
+	rt.api.Fail(w, NewError(http.StatusNotFound, "", ""))
+}
+
+// Param returns path parameter captured by Router.
+// It returns empty string if name is not captured.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+func (rt *route) serve(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	if rc := FromRequest(r); rc != nil {
+		rc.Route = rt.pattern
+	}
+
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+	}
+
+	rt.handler.ServeHTTP(w, r)
+}
+
+func (rt *route) match(path []string) (map[string]string, bool) {
+	var params map[string]string
+
+	for i, seg := range rt.segments {
+		if strings.HasPrefix(seg, "*") {
+			if params == nil {
+				params = map[string]string{}
+			}
+
+			params[seg[1:]] = strings.Join(path[i:], "/")
+			return params, true
+		}
+
+		if i >= len(path) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(seg, ":") {
+			if path[i] == "" {
+				return nil, false
+			}
+
+			if params == nil {
+				params = map[string]string{}
+			}
+
+			params[seg[1:]] = path[i]
+			continue
+		}
+
+		if seg != path[i] {
+			return nil, false
+		}
+	}
+
+	return params, len(rt.segments) == len(path)
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func appendMethod(methods []string, method string) []string {
+	for _, m := range methods {
+		if m == method {
+			return methods
+		}
+	}
+
+	return append(methods, method)
+}
@@ -0,0 +1,131 @@
+package dou
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouterAPI() (*API, *Router) {
+	Register("teststatus", &apiStatusRecorder{})
+
+	defer Deregister("teststatus")
+
+	a, err := NewAPI("teststatus")
+
+	if err != nil {
+		panic(err)
+	}
+
+	router := NewRouter(a)
+	a.Handler = router
+
+	return a, router
+}
+
+func TestRouter(t *testing.T) {
+	a, router := newTestRouterAPI()
+
+	respond := func(body string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + Param(r, "id") + Param(r, "path")))
+		}
+	}
+
+	router.Get("/users", respond("list"))
+	router.Post("/users", respond("create"))
+	router.Get("/users/:id", respond("show:"))
+	router.Delete("/users/:id", respond("delete:"))
+	router.Get("/files/*path", respond("file:"))
+
+	v1 := router.Group("/v1/")
+	v1.Put("/users/:id", respond("v1 update:"))
+	v1.Group("/admin").Patch("/users/:id", respond("v1 admin patch:"))
+
+	tests := []struct {
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"GET", "/users", http.StatusOK, "list"},
+		{"POST", "/users", http.StatusOK, "create"},
+		{"GET", "/users/1", http.StatusOK, "show:1"},
+		{"HEAD", "/users/1", http.StatusOK, "show:1"},
+		{"DELETE", "/users/2", http.StatusOK, "delete:2"},
+		{"GET", "/files/a/b.txt", http.StatusOK, "file:a/b.txt"},
+		{"PUT", "/v1/users/3", http.StatusOK, "v1 update:3"},
+		{"PATCH", "/v1/admin/users/4", http.StatusOK, "v1 admin patch:4"},
+		{"GET", "/users/1/friends", http.StatusNotFound, ""},
+		{"GET", "/users/", http.StatusNotFound, ""},
+		{"GET", "/v1/users/3", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest(test.method, test.path, nil)
+		response := httptest.NewRecorder()
+
+		a.ServeHTTP(response, request)
+
+		if response.Code != test.expectedCode {
+			t.Errorf("%s %s\nexpected code: %v\ngot: %v\n", test.method, test.path, test.expectedCode, response.Code)
+		}
+
+		if test.expectedBody != "" && response.Body.String() != test.expectedBody {
+			t.Errorf("%s %s\nexpected body: %v\ngot: %v\n", test.method, test.path, test.expectedBody, response.Body.String())
+		}
+	}
+}
+
+func TestRouterRespondsMethodNotAllowedWithAllowHeader(t *testing.T) {
+	request, _ := http.NewRequest("PUT", "/users/1", nil)
+	response := httptest.NewRecorder()
+
+	a, router := newTestRouterAPI()
+	router.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {})
+	router.Delete("/users/:id", func(w http.ResponseWriter, r *http.Request) {})
+
+	a.ServeHTTP(response, request)
+
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Router should respond 405\nexpected: %v\ngot: %v\n", http.StatusMethodNotAllowed, response.Code)
+	}
+
+	if got, expected := response.Header().Get("Allow"), "DELETE, GET, HEAD"; got != expected {
+		t.Errorf("Router should set Allow header\nexpected: %v\ngot: %v\n", expected, got)
+	}
+
+	gotBody := map[string]string{}
+
+	if err := json.Unmarshal(response.Body.Bytes(), &gotBody); err != nil {
+		panic(err)
+	}
+
+	if gotBody["message"] != http.StatusText(http.StatusMethodNotAllowed) {
+		t.Errorf("Router should render 405 by plugin, but got %s", response.Body.Bytes())
+	}
+}
+
+func TestRouterRespondsNotFoundByPlugin(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/unknown", nil)
+	response := httptest.NewRecorder()
+
+	a, _ := newTestRouterAPI()
+
+	a.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Router should respond 404\nexpected: %v\ngot: %v\n", http.StatusNotFound, response.Code)
+	}
+
+	gotBody := map[string]string{}
+
+	if err := json.Unmarshal(response.Body.Bytes(), &gotBody); err != nil {
+		panic(err)
+	}
+
+	if gotBody["message"] != http.StatusText(http.StatusNotFound) {
+		t.Errorf("Router should render 404 by plugin, but got %s", response.Body.Bytes())
+	}
+}
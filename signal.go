@@ -0,0 +1,135 @@
+package dou
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultDrainTimeout is how long API.RunUntilSignal waits for in-flight requests, and how long stop hooks can take, when API.ShutdownTimeout is zero.
+const DefaultDrainTimeout = 30 * time.Second
+
+// DefaultSignals are signals trapped by API.RunUntilSignal when no signal is given.
+var DefaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// AddTeardown registers f that is called by API.RunUntilSignal after the server stopped.
+// Teardown funcs are called in registered order even if some of them fail.
+func (api *API) AddTeardown(f func() error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.teardowns = append(api.teardowns, f)
+}
+
+// RunUntilSignal runs api server on l until one of signals is received. If no signal is given, DefaultSignals are used.
+// When signal is received, it drains in-flight requests for API.ShutdownTimeout at most, or DefaultDrainTimeout if it is zero.
+// Second signal cuts off remaining connections immediately, and the signals are handled by default after that.
+// And then it calls teardown funcs registered by API.AddTeardown.
+// It returns exit status. 0 means the server stopped gracefully and all teardown funcs succeeded.
+//
+//	os.Exit(api.RunUntilSignal(l))
+func (api *API) RunUntilSignal(l net.Listener, signals ...os.Signal) int {
+	if len(signals) == 0 {
+		signals = DefaultSignals
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	defer signal.Stop(c)
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- api.Run(l)
+	}()
+
+	status := 0
+
+	var err error
+
+	select {
+	case sig := <-c:
+		api.logger().Info("signal received. Stopping", slog.String("signal", sig.String()))
+
+		timeout := api.ShutdownTimeout
+
+		if timeout <= 0 {
+			timeout = DefaultDrainTimeout
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		done := make(chan struct{})
+
+		go func() {
+			select {
+			case sig := <-c:
+				api.logger().Warn("signal received again. Closing remaining connections", slog.String("signal", sig.String()))
+				signal.Stop(c)
+				cancel()
+			case <-done:
+			}
+		}()
+
+		if api.stopWhenRunning(ctx, runReturned) != nil {
+			status = 1
+		}
+
+		err = <-runReturned
+
+		close(done)
+		cancel()
+	case err = <-runReturned:
+	}
+
+	if err != ErrServerStopped {
+		status = 1
+	}
+
+	if !api.teardown() {
+		status = 1
+	}
+
+	return status
+}
+
+// stopWhenRunning calls API.Shutdown with ctx. If the signal arrived before Run started serving, it retries until Run starts or returns.
+func (api *API) stopWhenRunning(ctx context.Context, runReturned chan error) error {
+	for {
+		err := api.Shutdown(ctx)
+
+		if err != ErrNotRunning {
+			return err
+		}
+
+		select {
+		case e := <-runReturned:
+			// Put back for RunUntilSignal.
+			runReturned <- e
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// teardown calls teardown funcs in order and reports whether all of them succeeded.
+func (api *API) teardown() bool {
+	api.mu.Lock()
+	teardowns := api.teardowns
+	api.mu.Unlock()
+
+	ok := true
+
+	for i, f := range teardowns {
+		if err := f(); err != nil {
+			api.logger().Error("teardown failed", slog.Int("index", i), slog.Any("error", err))
+			ok = false
+		}
+	}
+
+	return ok
+}
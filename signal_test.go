@@ -0,0 +1,153 @@
+package dou
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunUntilSignalStopsGracefully(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var order []int
+
+	a.AddTeardown(func() error {
+		order = append(order, 1)
+		return nil
+	})
+
+	a.AddTeardown(func() error {
+		order = append(order, 2)
+		return nil
+	})
+
+	status := make(chan int, 1)
+	go func() {
+		status <- a.RunUntilSignal(l, os.Interrupt)
+	}()
+
+	// Make sure the server is running.
+	res, err := http.Get("http://" + l.Addr().String() + "/")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+
+	p, _ := os.FindProcess(os.Getpid())
+
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skip("signal is not supported: ", err)
+	}
+
+	if got := <-status; got != 0 {
+		t.Errorf("API.RunUntilSignal should return 0 if the server stopped gracefully\nexpected: %v\ngot: %v\n", 0, got)
+	}
+
+	if expected := []int{1, 2}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("teardown funcs should be called in order\nexpected: %v\ngot: %v\n", expected, order)
+	}
+}
+
+func TestRunUntilSignalReturnsFailureStatus(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer l.Close()
+
+	a := newTestAPI()
+	a.Logger, _ = newTestLogger()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var tornDown []int
+
+	a.AddTeardown(func() error {
+		tornDown = append(tornDown, 1)
+		return errors.New("flush failed")
+	})
+
+	a.AddTeardown(func() error {
+		tornDown = append(tornDown, 2)
+		return nil
+	})
+
+	if got := a.RunUntilSignal(&brokenListener{l}); got != 1 {
+		t.Errorf("API.RunUntilSignal should return 1 if the server failed\nexpected: %v\ngot: %v\n", 1, got)
+	}
+
+	if expected := []int{1, 2}; !reflect.DeepEqual(tornDown, expected) {
+		t.Errorf("all teardown funcs should be called even if some of them fail\nexpected: %v\ngot: %v\n", expected, tornDown)
+	}
+}
+
+func TestRunUntilSignalClosesConnectionsBySecondSignal(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	a := newTestAPI()
+	a.Logger, _ = newTestLogger()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+
+	status := make(chan int, 1)
+	go func() {
+		status <- a.RunUntilSignal(l, os.Interrupt)
+	}()
+
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/")
+
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	<-entered
+
+	p, _ := os.FindProcess(os.Getpid())
+
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skip("signal is not supported: ", err)
+	}
+
+	// Wait until draining started.
+	for a.Ready(context.Background()) {
+		time.Sleep(time.Millisecond)
+	}
+
+	p.Signal(os.Interrupt)
+
+	select {
+	case got := <-status:
+		if got != 1 {
+			t.Errorf("API.RunUntilSignal should return 1 if in-flight requests are cut off\nexpected: %v\ngot: %v\n", 1, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second signal should close remaining connections")
+	}
+}
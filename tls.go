@@ -0,0 +1,155 @@
+package dou
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCertCheckInterval is default CertReloader.CheckInterval set by NewCertReloader.
+const DefaultCertCheckInterval = 10 * time.Second
+
+// TLSListener returns listener that accepts TLS connections on l by config.
+// HTTP/2 is negotiated by ALPN if config.NextProtos is empty.
+// API.Restart hands l over to new process, so it should wrap inherited listener again.
+//
+//	reloader, err := dou.NewCertReloader("server.crt", "server.key")
+//	// ...
+//	err = api.RunListeners(dou.TLSListener(public, reloader.TLSConfig()), internal)
+func TLSListener(l net.Listener, config *tls.Config) net.Listener {
+	config = config.Clone()
+
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	return &tlsListener{Listener: tls.NewListener(l, config), raw: l}
+}
+
+// tlsListener keeps raw listener for handing it over by API.Restart.
+type tlsListener struct {
+	net.Listener
+	raw net.Listener
+}
+
+// File returns file of the raw listener.
+func (l *tlsListener) File() (*os.File, error) {
+	return listenerFile(l.raw)
+}
+
+// CertReloader serves certificate loaded from CertFile and KeyFile, and reloads them when they are changed on disk.
+// Files are checked on TLS handshake at most once per CheckInterval.
+// If reloading fails, the last certificate continues to be served and the error is logged by Logger.
+type CertReloader struct {
+	CertFile      string
+	KeyFile       string
+	CheckInterval time.Duration
+
+	// Logger logs errors of reloading. If this is nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewCertReloader new CertReloader and loads certificate.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	cr := &CertReloader{CertFile: certFile, KeyFile: keyFile, CheckInterval: DefaultCertCheckInterval}
+
+	err := cr.Reload()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// Reload loads certificate from disk immediately.
+func (cr *CertReloader) Reload() error {
+	modTime, err := cr.latestModTime()
+
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.CertFile, cr.KeyFile)
+
+	if err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.checked = time.Now()
+	cr.mu.Unlock()
+
+	return nil
+}
+
+// GetCertificate returns current certificate. This is for tls.Config.GetCertificate.
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	check := time.Since(cr.checked) >= cr.CheckInterval
+	modTime := cr.modTime
+
+	if check {
+		cr.checked = time.Now()
+	}
+
+	cr.mu.Unlock()
+
+	if check {
+		latest, err := cr.latestModTime()
+
+		if err == nil && latest.After(modTime) {
+			err = cr.Reload()
+		}
+
+		if err != nil {
+			cr.logger().Error("fail to reload certificate", slog.String("cert", cr.CertFile), slog.Any("error", err))
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	return cr.cert, nil
+}
+
+// TLSConfig returns tls.Config that serves certificate by cr.
+func (cr *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: cr.GetCertificate}
+}
+
+func (cr *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, name := range []string{cr.CertFile, cr.KeyFile} {
+		fi, err := os.Stat(name)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+func (cr *CertReloader) logger() *slog.Logger {
+	if cr.Logger != nil {
+		return cr.Logger
+	}
+
+	return slog.Default()
+}
@@ -0,0 +1,204 @@
+package dou
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, dir, commonName string, modTime time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+
+	for name, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+func commonNameOf(t *testing.T, cr *CertReloader) string {
+	cert, err := cr.GetCertificate(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return x.Subject.CommonName
+}
+
+func TestCertReloaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	certFile, keyFile := writeTestCert(t, dir, "old", now.Add(-time.Minute))
+
+	cr, err := NewCertReloader(certFile, keyFile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr.CheckInterval = 0
+
+	if cn := commonNameOf(t, cr); cn != "old" {
+		t.Errorf("CertReloader should serve loaded certificate\nexpected: %v\ngot: %v\n", "old", cn)
+	}
+
+	writeTestCert(t, dir, "new", now)
+
+	if cn := commonNameOf(t, cr); cn != "new" {
+		t.Errorf("CertReloader should reload changed certificate\nexpected: %v\ngot: %v\n", "new", cn)
+	}
+
+	// Broken files keep the last certificate.
+	os.WriteFile(certFile, []byte("broken"), 0600)
+	os.Chtimes(certFile, now.Add(time.Minute), now.Add(time.Minute))
+
+	cr.Logger, _ = newTestLogger()
+
+	if cn := commonNameOf(t, cr); cn != "new" {
+		t.Errorf("CertReloader should keep the last certificate if reloading fails\nexpected: %v\ngot: %v\n", "new", cn)
+	}
+}
+
+func TestNewCertReloaderReturnsError(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewCertReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"))
+
+	if err == nil {
+		t.Error("NewCertReloader should return error if files are missing")
+	}
+}
+
+func TestRunListenersServesTLSAndPlain(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), "localhost", time.Now())
+
+	cr, err := NewCertReloader(certFile, keyFile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	internal, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		panic(err)
+	}
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Write([]byte(r.Proto + " tls"))
+		} else {
+			w.Write([]byte(r.Proto + " plain"))
+		}
+	})
+
+	runReturned := make(chan error, 1)
+	go func() {
+		runReturned <- a.RunListeners(TLSListener(public, cr.TLSConfig()), internal)
+	}()
+
+	pool := x509.NewCertPool()
+	caPEM, _ := os.ReadFile(certFile)
+	pool.AppendCertsFromPEM(caPEM)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+
+	for url, expected := range map[string]string{
+		"https://" + public.Addr().String() + "/":  "HTTP/2.0 tls",
+		"http://" + internal.Addr().String() + "/": "HTTP/1.1 plain",
+	} {
+		res, err := client.Get(url)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if string(b) != expected {
+			t.Errorf("API.RunListeners should serve %s\nexpected: %v\ngot: %v\n", url, expected, string(b))
+		}
+	}
+
+	if err := a.Stop(); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-runReturned; err != ErrServerStopped {
+		t.Errorf("API.RunListeners should return ErrServerStopped after all listeners are stopped\nexpected: %v\ngot: %v\n", ErrServerStopped, err)
+	}
+
+	for _, l := range []net.Listener{public, internal} {
+		if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+			t.Errorf("API.Stop should close listener %s", l.Addr())
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package dou
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Optional interfaces of http.ResponseWriter that are preserved by WrapWriter.
+const (
+	flusherBit = 1 << iota
+	hijackerBit
+	closeNotifierBit
+	readerFromBit
+	pusherBit
+)
+
+// unwrapper provides Unwrap for wrapped writers.
+// It is used by SafeWriterOf, API.PluginFor and http.ResponseController.
+type unwrapper struct {
+	w http.ResponseWriter
+}
+
+// Unwrap returns wrapped http.ResponseWriter.
+func (u unwrapper) Unwrap() http.ResponseWriter {
+	return u.w
+}
+
+// WrapWriter returns http.ResponseWriter that behaves as w,
+// and implements the optional interfaces http.Hijacker, http.CloseNotifier and http.Pusher that underlying implements.
+// Their methods are delegated to underlying.
+// http.Flusher and io.ReaderFrom are implemented only if w implements them, and they call w's methods.
+// So bytes written through them never bypass w.Write, e.g. when w compresses response body.
+// Unwrap method of returned writer returns w.
+// w should implement `Unwrap() http.ResponseWriter` too, so that SafeWriterOf can find SafeWriter through it.
+//
+// Use this when BeforeDispatch of plugin wraps http.ResponseWriter.
+//
+//	return dou.WrapWriter(&myWriter{ResponseWriter: w}, w), r
+func WrapWriter(w, underlying http.ResponseWriter) http.ResponseWriter {
+	const ownBits = flusherBit | readerFromBit
+
+	bits := optionalBits(underlying)&^ownBits | optionalBits(w)&ownBits
+	return wrapWriter(w, bits, wrappedWriterOps{w: w, underlying: underlying})
+}
+
+// wrap returns sw that implements exactly the optional interfaces that sw.ResponseWriter implements.
+// Their methods are called through SafeWriter to record the response.
+func (sw *SafeWriter) wrap() http.ResponseWriter {
+	return wrapWriter(sw, optionalBits(sw.ResponseWriter), (*safeWriterOps)(sw))
+}
+
+// optionalBits returns bits of the optional interfaces that w implements.
+func optionalBits(w interface{}) int {
+	var bits int
+
+	if _, ok := w.(http.Flusher); ok {
+		bits |= flusherBit
+	}
+
+	if _, ok := w.(http.Hijacker); ok {
+		bits |= hijackerBit
+	}
+
+	if _, ok := w.(http.CloseNotifier); ok {
+		bits |= closeNotifierBit
+	}
+
+	if _, ok := w.(io.ReaderFrom); ok {
+		bits |= readerFromBit
+	}
+
+	if _, ok := w.(http.Pusher); ok {
+		bits |= pusherBit
+	}
+
+	return bits
+}
+
+// wrapWriter returns w that implements the optional interfaces of bits.
+// Their methods are delegated to ops. ops should implement all of them.
+func wrapWriter(w http.ResponseWriter, bits int, ops interface{}) http.ResponseWriter {
+	u := unwrapper{w}
+
+	switch bits {
+	case 0:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+		}{w, u}
+	case flusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+		}{w, u, ops.(http.Flusher)}
+	case hijackerBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+		}{w, u, ops.(http.Hijacker)}
+	case flusherBit | hijackerBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker)}
+	case closeNotifierBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.CloseNotifier
+		}{w, u, ops.(http.CloseNotifier)}
+	case flusherBit | closeNotifierBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.CloseNotifier
+		}{w, u, ops.(http.Flusher), ops.(http.CloseNotifier)}
+	case hijackerBit | closeNotifierBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			http.CloseNotifier
+		}{w, u, ops.(http.Hijacker), ops.(http.CloseNotifier)}
+	case flusherBit | hijackerBit | closeNotifierBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(http.CloseNotifier)}
+	case readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			io.ReaderFrom
+		}{w, u, ops.(io.ReaderFrom)}
+	case flusherBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+		}{w, u, ops.(http.Flusher), ops.(io.ReaderFrom)}
+	case hijackerBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{w, u, ops.(http.Hijacker), ops.(io.ReaderFrom)}
+	case flusherBit | hijackerBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(io.ReaderFrom)}
+	case closeNotifierBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.CloseNotifier
+			io.ReaderFrom
+		}{w, u, ops.(http.CloseNotifier), ops.(io.ReaderFrom)}
+	case flusherBit | closeNotifierBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.CloseNotifier
+			io.ReaderFrom
+		}{w, u, ops.(http.Flusher), ops.(http.CloseNotifier), ops.(io.ReaderFrom)}
+	case hijackerBit | closeNotifierBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+		}{w, u, ops.(http.Hijacker), ops.(http.CloseNotifier), ops.(io.ReaderFrom)}
+	case flusherBit | hijackerBit | closeNotifierBit | readerFromBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(http.CloseNotifier), ops.(io.ReaderFrom)}
+	case pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Pusher
+		}{w, u, ops.(http.Pusher)}
+	case flusherBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.Pusher)}
+	case hijackerBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			http.Pusher
+		}{w, u, ops.(http.Hijacker), ops.(http.Pusher)}
+	case flusherBit | hijackerBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(http.Pusher)}
+	case closeNotifierBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.CloseNotifier
+			http.Pusher
+		}{w, u, ops.(http.CloseNotifier), ops.(http.Pusher)}
+	case flusherBit | closeNotifierBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.CloseNotifier
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.CloseNotifier), ops.(http.Pusher)}
+	case hijackerBit | closeNotifierBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			http.CloseNotifier
+			http.Pusher
+		}{w, u, ops.(http.Hijacker), ops.(http.CloseNotifier), ops.(http.Pusher)}
+	case flusherBit | hijackerBit | closeNotifierBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(http.CloseNotifier), ops.(http.Pusher)}
+	case readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case flusherBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case hijackerBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.Hijacker), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case flusherBit | hijackerBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case closeNotifierBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.CloseNotifier), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case flusherBit | closeNotifierBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.CloseNotifier), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case hijackerBit | closeNotifierBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.Hijacker), ops.(http.CloseNotifier), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	case flusherBit | hijackerBit | closeNotifierBit | readerFromBit | pusherBit:
+		return struct {
+			http.ResponseWriter
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{w, u, ops.(http.Flusher), ops.(http.Hijacker), ops.(http.CloseNotifier), ops.(io.ReaderFrom), ops.(http.Pusher)}
+	}
+
+	// unreachable
+	return w
+}
+
+// wrappedWriterOps implements the optional interfaces for WrapWriter.
+// Flush and ReadFrom are delegated to w, and the others are delegated to underlying.
+type wrappedWriterOps struct {
+	w          http.ResponseWriter
+	underlying http.ResponseWriter
+}
+
+func (o wrappedWriterOps) Flush() {
+	o.w.(http.Flusher).Flush()
+}
+
+func (o wrappedWriterOps) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return o.underlying.(http.Hijacker).Hijack()
+}
+
+func (o wrappedWriterOps) CloseNotify() <-chan bool {
+	return o.underlying.(http.CloseNotifier).CloseNotify()
+}
+
+func (o wrappedWriterOps) ReadFrom(src io.Reader) (int64, error) {
+	return o.w.(io.ReaderFrom).ReadFrom(src)
+}
+
+func (o wrappedWriterOps) Push(target string, opts *http.PushOptions) error {
+	return o.underlying.(http.Pusher).Push(target, opts)
+}
+
+// safeWriterOps implements the optional interfaces for SafeWriter.
+// They record the response as same as SafeWriter.Write.
+type safeWriterOps SafeWriter
+
+func (o *safeWriterOps) Flush() {
+	sw := (*SafeWriter)(o)
+
+	if !sw.WroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+
+	sw.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (o *safeWriterOps) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	sw := (*SafeWriter)(o)
+
+	conn, rw, err := sw.ResponseWriter.(http.Hijacker).Hijack()
+
+	if err == nil {
+		// The connection is not managed by http.Server anymore.
+		// Prevent OnPanic from writing response.
+		sw.Hijacked = true
+		sw.WroteHeader = true
+
+		if sw.Status == 0 {
+			// Access log and metrics should not record hijacked connection as 200. e.g. WebSocket upgrade
+			sw.Status = http.StatusSwitchingProtocols
+		}
+	}
+
+	return conn, rw, err
+}
+
+func (o *safeWriterOps) CloseNotify() <-chan bool {
+	return o.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+func (o *safeWriterOps) ReadFrom(src io.Reader) (int64, error) {
+	sw := (*SafeWriter)(o)
+
+	if !sw.WroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+
+	n, err := sw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	sw.Wrote = sw.Wrote || n > 0
+	sw.Written += n
+	return n, err
+}
+
+func (o *safeWriterOps) Push(target string, opts *http.PushOptions) error {
+	return o.ResponseWriter.(http.Pusher).Push(target, opts)
+}
@@ -0,0 +1,254 @@
+package dou
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type optionalInterfaces struct {
+	flusher       bool
+	hijacker      bool
+	closeNotifier bool
+	readerFrom    bool
+	pusher        bool
+}
+
+func optionalInterfacesOf(w http.ResponseWriter) optionalInterfaces {
+	var oi optionalInterfaces
+	_, oi.flusher = w.(http.Flusher)
+	_, oi.hijacker = w.(http.Hijacker)
+	_, oi.closeNotifier = w.(http.CloseNotifier)
+	_, oi.readerFrom = w.(io.ReaderFrom)
+	_, oi.pusher = w.(http.Pusher)
+	return oi
+}
+
+func TestServeHTTPPreservesOptionalInterfaces(t *testing.T) {
+	var got, expected optionalInterfaces
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = optionalInterfacesOf(w)
+
+		if _, ok := SafeWriterOf(w); !ok {
+			t.Error("SafeWriterOf should find SafeWriter from wrapped writer")
+		}
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected = optionalInterfacesOf(w)
+		a.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+
+	if err != nil {
+		panic(err)
+	}
+
+	res.Body.Close()
+
+	if got != expected {
+		t.Errorf("API.ServeHTTP should preserve optional interfaces\nexpected: %+v\ngot: %+v\n", expected, got)
+	}
+
+	response := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	expected = optionalInterfacesOf(response)
+
+	a.ServeHTTP(response, request)
+
+	if got != expected {
+		t.Errorf("API.ServeHTTP should expose only optional interfaces that underlying implements\nexpected: %+v\ngot: %+v\n", expected, got)
+	}
+}
+
+func TestSafeWriterRecordsFlushAndReadFrom(t *testing.T) {
+	var sw *SafeWriter
+
+	a := newTestAPI()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw, _ = SafeWriterOf(w)
+
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("http.ResponseController should be able to flush, but got %v", err)
+		}
+
+		io.Copy(w, strings.NewReader("hello"))
+	})
+
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+
+	if string(b) != "hello" {
+		t.Errorf("unexpected body\nexpected: %v\ngot: %s\n", "hello", b)
+	}
+
+	if sw.Status != http.StatusOK || sw.Written != 5 || !sw.Wrote {
+		t.Errorf("SafeWriter should record flush and io.ReaderFrom\ngot: status=%v written=%v wrote=%v\n", sw.Status, sw.Written, sw.Wrote)
+	}
+}
+
+func TestOnPanicAfterHijack(t *testing.T) {
+	a := newTestAPI()
+	a.LogStackTrace = false
+	a.Metrics = NewMetrics()
+	a.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+
+		if err != nil {
+			panic(err)
+		}
+
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 418 I'm a teapot\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+
+		if sw, _ := SafeWriterOf(w); !sw.Hijacked || !sw.WroteHeader || sw.Status != http.StatusSwitchingProtocols {
+			t.Errorf("SafeWriter should record hijacking as %v, but got %+v", http.StatusSwitchingProtocols, sw)
+		}
+	})
+
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+
+	if err != nil {
+		panic(err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusTeapot {
+		t.Errorf("hijacked connection should be written by handler\nexpected: %v\ngot: %v\n", http.StatusTeapot, res.StatusCode)
+	}
+
+	// Handler may still be running after the client read the response.
+	buf := &bytes.Buffer{}
+
+	for i := 0; i < 100; i++ {
+		buf.Reset()
+		a.Metrics.WriteTo(buf)
+
+		if strings.Contains(buf.String(), "dou_http_requests_total{") {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if expected := `status="101"`; !strings.Contains(buf.String(), expected) {
+		t.Errorf("metrics should record hijacked connection as 101\nexpected: %v\ngot: %v\n", expected, buf.String())
+	}
+}
+
+type pluginWriter struct {
+	http.ResponseWriter
+}
+
+func (pw *pluginWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// flushingWriter is pluginWriter that implements http.Flusher.
+type flushingWriter struct {
+	pluginWriter
+}
+
+func (fw *flushingWriter) Flush() {
+	fw.ResponseWriter.(http.Flusher).Flush()
+}
+
+// upperWriter is pluginWriter that transforms bytes written.
+type upperWriter struct {
+	pluginWriter
+}
+
+func (uw *upperWriter) Write(b []byte) (int, error) {
+	return uw.ResponseWriter.Write(bytes.ToUpper(b))
+}
+
+func TestWrapWriter(t *testing.T) {
+	response := httptest.NewRecorder()
+	sw := NewSafeWriter(response)
+
+	w := WrapWriter(&pluginWriter{sw.wrap()}, sw.wrap())
+
+	if _, ok := w.(http.Flusher); ok {
+		t.Error("WrapWriter should not implement http.Flusher that w doesn't implement")
+	}
+
+	if _, ok := w.(io.ReaderFrom); ok {
+		t.Error("WrapWriter should not implement io.ReaderFrom that w doesn't implement")
+	}
+
+	if _, ok := w.(http.Hijacker); ok {
+		t.Error("WrapWriter should not implement http.Hijacker that underlying doesn't implement")
+	}
+
+	if got, ok := SafeWriterOf(w); !ok || got != sw {
+		t.Error("SafeWriterOf should find SafeWriter through WrapWriter")
+	}
+
+	w = WrapWriter(&flushingWriter{pluginWriter{sw.wrap()}}, sw.wrap())
+
+	f, ok := w.(http.Flusher)
+
+	if !ok {
+		t.Fatal("WrapWriter should implement http.Flusher that w implements")
+	}
+
+	f.Flush()
+
+	if !response.Flushed || sw.Status != http.StatusOK {
+		t.Error("Flush should be delegated to SafeWriter through w")
+	}
+}
+
+func TestWrapWriterReadFrom(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := NewSafeWriter(w)
+		ww := WrapWriter(&upperWriter{pluginWriter{sw.wrap()}}, sw.wrap())
+
+		if _, ok := ww.(io.ReaderFrom); ok {
+			t.Error("WrapWriter should not implement io.ReaderFrom that w doesn't implement")
+		}
+
+		if _, err := io.Copy(ww, io.LimitReader(strings.NewReader("hello"), 5)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer res.Body.Close()
+
+	b, _ := ioutil.ReadAll(res.Body)
+
+	if got := string(b); got != "HELLO" {
+		t.Errorf("io.Copy should write through w.Write\nexpected: %v\ngot: %v\n", "HELLO", got)
+	}
+}